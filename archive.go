@@ -0,0 +1,182 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Is the path a zip or tar archive to count the content of
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count the files inside an archive without extracting it, each file is
+// named by the path of the archive joined with its name inside
+func (s *Scanner) scanArchive(archive string) (*Result, error) {
+	result := s.newResult()
+	keep := s.entryFilter(archive)
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		r, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		for _, entry := range r.File {
+			if !entry.FileInfo().Mode().IsRegular() || !keep(entry.Name) {
+				continue
+			}
+			if err := s.addEntry(result, archive, entry.Name, entry.FileInfo(), entry.Open); err != nil {
+				return nil, err
+			}
+		}
+		result.markDuplicates()
+		return result, nil
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(archive), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if !header.FileInfo().Mode().IsRegular() || !keep(header.Name) {
+			continue
+		}
+		data, counted, err := s.readEntry(tr, header)
+		if err != nil {
+			return nil, err
+		} else if !counted {
+			continue
+		}
+		open := func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+		if err := s.addEntry(result, archive, header.Name, header.FileInfo(), open); err != nil {
+			return nil, err
+		}
+	}
+	result.markDuplicates()
+	return result, nil
+}
+
+// Bytes read of a tar entry too large to count, enough to tell its
+// language by its content
+const entryHead = 4096
+
+// Read what is needed of a tar entry, which can only be read once as the
+// archive streams past, false when it is not counted.  Entries of no
+// language by their name are passed over unread unless they may start
+// with #!, and an inventory or an entry too large reads none or only the
+// start of it.
+func (s *Scanner) readEntry(tr io.Reader, header *tar.Header) ([]byte, bool, error) {
+	_, found := Detect(header.Name)
+	if !found && (s.Inventory || filepath.Ext(header.Name) != "") {
+		return nil, false, nil
+	}
+	limit := header.Size
+	if s.Inventory {
+		limit = 0
+	} else if s.tooLarge(header.FileInfo()) && limit > entryHead {
+		limit = entryHead
+	}
+	data := []byte{}
+	if !found {
+		head, err := ioutil.ReadAll(io.LimitReader(tr, 256))
+		if err != nil {
+			return nil, false, err
+		}
+		line := strings.SplitN(string(head), "\n", 2)[0]
+		if _, found := detectShebang(strings.TrimSpace(line)); !found {
+			return nil, false, nil
+		}
+		data = head
+	}
+	rest, err := ioutil.ReadAll(io.LimitReader(tr, limit-int64(len(data))))
+	if err != nil {
+		return nil, false, err
+	}
+	return append(data, rest...), true, nil
+}
+
+// Which files of the archive are counted, by the same rules as the files
+// found walking a directory other than ignore files
+func (s *Scanner) entryFilter(archive string) func(name string) bool {
+	excludes := compileGlobs(s.Exclude)
+	includes := compileGlobs(s.Include)
+	return func(name string) bool {
+		name = filepath.ToSlash(filepath.Clean(strings.TrimPrefix(name, "/")))
+		if s.Omit != nil && s.Omit.MatchString(filepath.Join(archive, name)) {
+			return false
+		}
+		dirs := strings.Split(name, "/")
+		for i, dir := range dirs[:len(dirs)-1] {
+			if strings.HasPrefix(dir, ".") || dir == "__pycache__" ||
+				matchGlobs(excludes, strings.Join(dirs[:i+1], "/"), true) {
+
+				return false
+			}
+		}
+		if matchGlobs(excludes, name, false) {
+			return false
+		}
+		return len(includes) == 0 || matchGlobs(includes, name, false)
+	}
+}
+
+// Add a file of the archive to the result
+func (s *Scanner) addEntry(result *Result, archive, name string, info os.FileInfo,
+	open func() (io.ReadCloser, error)) error {
+
+	return s.add(result, File{
+		Path: filepath.Join(archive, filepath.FromSlash(name)),
+		Root: archive,
+		Info: info,
+		open: open,
+	})
+}
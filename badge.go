@@ -0,0 +1,100 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// The words put before the count on a badge, the column name otherwise
+var badgeLabels = map[string]string{
+	"code":     "lines of code",
+	"lines":    "total lines",
+	"comments": "comment lines",
+	"blanks":   "blank lines",
+	"docs":     "doc lines",
+}
+
+// The template of a badge in the flat style of shields.io
+const badgeSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>
+<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>
+</g>
+</svg>
+`
+
+// Render a badge of a column of the counts, like "lines of code: 123k"
+func BadgeFor(totals Count, metric string) ([]byte, error) {
+	value, found := totals.Column(metric)
+	if !found {
+		return nil, fmt.Errorf("no badge for %q", metric)
+	}
+	label := badgeLabels[metric]
+	if label == "" {
+		label = metric
+	}
+	return Badge(label, ShortNumber(value), "#007ec6"), nil
+}
+
+// Render a badge of two halves, the label on grey and the message on color
+func Badge(label string, message string, color string) []byte {
+	left, right := textWidth(label)+10, textWidth(message)+10
+	label, message = html.EscapeString(label), html.EscapeString(message)
+	return []byte(fmt.Sprintf(badgeSVG, left+right, left, right, label, message,
+		html.EscapeString(color), left/2, left+right/2))
+}
+
+// Shorten a count to a few digits, 7012 as 7k, 123456 as 123k and 1234567 as 1.2M
+func ShortNumber(n int) string {
+	value, unit := float64(n), ""
+	for _, next := range []string{"k", "M", "B"} {
+		if value < 1000 {
+			break
+		}
+		value, unit = value/1000, next
+	}
+	if unit == "" || value >= 10 {
+		return strconv.Itoa(int(value)) + unit
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + unit
+}
+
+// The width in pixels of text in Verdana at 11px, near enough to size a badge
+func textWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		switch {
+		case r == ' ' || r == 'i' || r == 'l' || r == 'j' || r == '.' || r == ',' ||
+			r == ':' || r == '\'' || r == '|' || r == '!' || r == 'I':
+			width += 4
+		case r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			width += 10
+		default:
+			width += 7
+		}
+	}
+	return width
+}
@@ -0,0 +1,232 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Cache keeps the counts of files between runs so that only the files whose
+// size or modification time changed are scanned again
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	changed bool
+}
+
+// The counts of a file as it was when scanned
+type cacheEntry struct {
+	Size       int64             `json:"size"`
+	ModTime    int64             `json:"mtime"`
+	Language   string            `json:"language"`
+	Lines      int               `json:"lines"`
+	Comments   int               `json:"comments"`
+	Blanks     int               `json:"blanks"`
+	Code       int               `json:"code"`
+	Mixed      int               `json:"mixed,omitempty"`
+	Docs       int               `json:"docs,omitempty"`
+	Complexity int               `json:"complexity,omitempty"`
+	Functions  int               `json:"functions,omitempty"`
+	Longest    int               `json:"longest,omitempty"`
+	Chars      int               `json:"chars,omitempty"`
+	Generated  bool              `json:"generated,omitempty"`
+	Encoding   string            `json:"encoding,omitempty"`
+	LineEnding string            `json:"lineEnding,omitempty"`
+	Hash       string            `json:"hash"`
+	Embedded   map[string]*Count `json:"embedded,omitempty"`
+}
+
+// The default place of the cache, in the user cache directory
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "codecount", "files.json"), nil
+}
+
+// Load the cache from the file, starting empty when there is none
+func OpenCache(path string) (*Cache, error) {
+	c := &Cache{path: path, entries: map[string]cacheEntry{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		// A damaged cache is only a slower run
+		c.entries = map[string]cacheEntry{}
+	}
+	return c, nil
+}
+
+// Write the cache back to its file when anything was added, leaving out
+// the files since removed and those cached by another version.  Runs at
+// the same time each write a file of their own, the last renamed wins.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.changed {
+		return nil
+	}
+	for key := range c.entries {
+		fields := strings.SplitN(key, "|", cacheFields)
+		if len(fields) != cacheFields || fields[0] != fmt.Sprint(cacheVersion) {
+			delete(c.entries, key)
+		} else if _, err := os.Lstat(fields[cacheFields-1]); os.IsNotExist(err) {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+// Forget every file and remove the cache file
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cacheEntry{}
+	c.changed = false
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Version of the counts cached, raised when the counts of a file change
+// so that those cached before are scanned again
+const cacheVersion = 3
+
+// Fields of a key: the version, the options, the language table and the
+// path last, as it may hold any character
+const cacheFields = 6
+
+// The key of a file, counts differ with the options of the scanner and
+// the definitions of the languages
+func (s *Scanner) cacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return fmt.Sprintf("%d|%t%t%t%t%t%t%t|%v|%v|%s|%s", cacheVersion,
+		s.EmbeddedSQL, s.CountPartial, s.DocStringsAsCode, s.SplitEmbedded,
+		s.IncludeGenerated, s.Complexity, s.Functions, s.NoComments, s.OnlyComments,
+		languagesHash, path)
+}
+
+// The hash of the language table, changed by AddLanguages
+var languagesHash = hashLanguages()
+
+func hashLanguages() string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%v", languages)))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// The file as cached when its size and modification time are unchanged
+func (c *Cache) lookup(key string, info os.FileInfo) (File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[key]
+	if !found || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return File{}, false
+	}
+	lang := languageNamed(entry.Language)
+	if lang.Name == "" {
+		return File{}, false
+	}
+	return File{
+		Info:       info,
+		Lang:       lang,
+		Scanned:    true,
+		Lines:      entry.Lines,
+		Comments:   entry.Comments,
+		Blanks:     entry.Blanks,
+		Code:       entry.Code,
+		Mixed:      entry.Mixed,
+		Docs:       entry.Docs,
+		Complexity: entry.Complexity,
+		Functions:  entry.Functions,
+		Longest:    entry.Longest,
+		Chars:      entry.Chars,
+		Generated:  entry.Generated,
+		Encoding:   entry.Encoding,
+		LineEnding: entry.LineEnding,
+		Embedded:   entry.Embedded,
+		Hash:       entry.Hash,
+	}, true
+}
+
+// Keep the counts of a scanned file
+func (c *Cache) store(key string, file File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{
+		Size:       file.Info.Size(),
+		ModTime:    file.Info.ModTime().UnixNano(),
+		Language:   file.Lang.Name,
+		Lines:      file.Lines,
+		Comments:   file.Comments,
+		Blanks:     file.Blanks,
+		Code:       file.Code,
+		Mixed:      file.Mixed,
+		Docs:       file.Docs,
+		Complexity: file.Complexity,
+		Functions:  file.Functions,
+		Longest:    file.Longest,
+		Chars:      file.Chars,
+		Generated:  file.Generated,
+		Encoding:   file.Encoding,
+		LineEnding: file.LineEnding,
+		Hash:       file.Hash,
+		Embedded:   file.Embedded,
+	}
+	c.changed = true
+}
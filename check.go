@@ -0,0 +1,82 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Check is a limit on a column of the totals, as in code > 100000
+type Check struct {
+	Column string
+	Op     string
+	Value  int
+}
+
+var checkExpr = regexp.MustCompile(`^\s*(\w+)\s*(>=|<=|==|!=|>|<)\s*(\d+)\s*$`)
+
+// Parse a limit written as column, comparison and number
+func ParseCheck(expr string) (Check, error) {
+	m := checkExpr.FindStringSubmatch(expr)
+	if m == nil {
+		return Check{}, fmt.Errorf("limit %q is not column op number", expr)
+	}
+	if _, found := (Count{}).Column(m[1]); !found {
+		return Check{}, fmt.Errorf("limit %q has no column %s", expr, m[1])
+	}
+	value, err := strconv.Atoi(m[3])
+	if err != nil {
+		return Check{}, err
+	}
+	return Check{m[1], m[2], value}, nil
+}
+
+// Does the count meet the condition of the check
+func (c Check) Holds(count Count) bool {
+	value, _ := count.Column(c.Column)
+	switch c.Op {
+	case ">":
+		return value > c.Value
+	case ">=":
+		return value >= c.Value
+	case "<":
+		return value < c.Value
+	case "<=":
+		return value <= c.Value
+	case "==":
+		return value == c.Value
+	}
+	return value != c.Value
+}
+
+func (c Check) String() string {
+	return fmt.Sprintf("%s %s %d", c.Column, c.Op, c.Value)
+}
+
+// The counted files with more than the number of lines
+func (r *Result) FilesOver(lines int) Files {
+	over := Files{}
+	for _, file := range r.Files {
+		if file.Counted() && file.Lines > lines {
+			over = append(over, file)
+		}
+	}
+	return over
+}
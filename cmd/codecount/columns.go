@@ -0,0 +1,171 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+
+package main
+
+import (
+	"codecount"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A numeric column of the text report, written as a number unless it
+// has its own text
+type column struct {
+	Title string
+	Value func(codecount.Count) int
+	Text  func(codecount.Count) string
+}
+
+// The text of the column for the count
+func (col column) text(c codecount.Count) string {
+	if col.Text != nil {
+		return col.Text(c)
+	}
+	return strconv.Itoa(col.Value(c))
+}
+
+// A size in bytes to read at a glance, as 12.3K in units of 1024
+func humanSize(size int64) string {
+	if size < 1024 {
+		return strconv.FormatInt(size, 10)
+	}
+	value, unit := float64(size), ""
+	for _, unit = range []string{"K", "M", "G", "T"} {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + unit
+}
+
+var columns = map[string]column{
+	"files":      {"Files", func(c codecount.Count) int { return c.Files }, nil},
+	"blanks":     {"Blank", func(c codecount.Count) int { return c.Blanks }, nil},
+	"comments":   {"Comment", func(c codecount.Count) int { return c.Comments }, nil},
+	"code":       {"Code", func(c codecount.Count) int { return c.Code }, nil},
+	"mixed":      {"Mixed", func(c codecount.Count) int { return c.Mixed }, nil},
+	"docs":       {"Docs", func(c codecount.Count) int { return c.Docs }, nil},
+	"lines":      {"Lines", func(c codecount.Count) int { return c.Lines }, nil},
+	"complexity": {"Complex", func(c codecount.Count) int { return c.Complexity }, nil},
+	"functions":  {"Funcs", func(c codecount.Count) int { return c.Functions }, nil},
+	"longest":    {"Longest", func(c codecount.Count) int { return c.Longest }, nil},
+	"average":    {"Average", func(c codecount.Count) int { return c.Average() }, nil},
+	"bytes":      {"Bytes", func(c codecount.Count) int { return int(c.Bytes) }, nil},
+	"size": {"Size", func(c codecount.Count) int { return int(c.Bytes) },
+		func(c codecount.Count) string { return humanSize(c.Bytes) }},
+}
+
+// The columns shown and their widths, sized by sizeColumns
+var layout = struct {
+	Columns   []column
+	NameWidth int
+	Width     int
+}{NameWidth: 29, Width: 10}
+
+// Choose the columns from -columns, the name always coming first, and fit
+// the names of the rows within the width of the terminal
+func sizeColumns(rows []codecount.Group) error {
+	names := []string{"files", "blanks", "comments", "code", "lines"}
+	if *ARG_PARTIAL {
+		names = []string{"files", "blanks", "comments", "code", "mixed", "lines"}
+	}
+	if *ARG_COMPLEXITY {
+		names = append(names, "complexity")
+	}
+	if *ARG_FUNCTIONS {
+		names = append(names, "functions")
+	}
+	if *ARG_LENGTH {
+		names = append(names, "longest", "average")
+	}
+	if *ARG_SIZE {
+		names = append(names, "size")
+	}
+	if *ARG_COLUMNS != "" {
+		names = strings.Split(*ARG_COLUMNS, ",")
+	}
+	layout.Columns = nil
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "name" {
+			continue
+		}
+		col, ok := columns[name]
+		if !ok {
+			col, ok = columns[name+"s"]
+		}
+		if !ok {
+			return fmt.Errorf("unknown column %q", name)
+		}
+		layout.Columns = append(layout.Columns, col)
+	}
+
+	// Six counts or more only fit the default width when narrower
+	layout.Width = 10
+	if len(layout.Columns) > 5 {
+		layout.Width = 8
+	}
+	longest := 0
+	for _, row := range rows {
+		if len(row.Name) > longest {
+			longest = len(row.Name)
+		}
+	}
+	layout.NameWidth = terminalWidth() - layout.Width*len(layout.Columns)
+	if longest < layout.NameWidth {
+		layout.NameWidth = longest
+	}
+	if layout.NameWidth < 29 {
+		layout.NameWidth = 29
+	}
+	return nil
+}
+
+// The width of the terminal from COLUMNS or the terminal itself, 79 when
+// the output is not a terminal
+func terminalWidth() int {
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width - 1
+	}
+	if width := terminalSize(os.Stdout); width > 0 {
+		return width - 1
+	}
+	return 79
+}
+
+// A line across the report
+func reportRule() {
+	fmt.Println(strings.Repeat("-", layout.NameWidth+layout.Width*len(layout.Columns)))
+}
+
+// Shorten a name to fit the name column, keeping the end of paths
+func fitName(name string) string {
+	width := layout.NameWidth
+	if len(name) <= width {
+		return name
+	}
+	if *ARG_BYFILE {
+		return name[0:width-2] + ".."
+	}
+	head := (width + 1) / 3
+	return name[0:head] + "..." + name[len(name)-(width-head-3):]
+}
@@ -0,0 +1,120 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+
+package main
+
+import (
+	"codecount"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Names of the configuration file, looked for in the home directory and
+// then the root
+var configNames = []string{".codecount.toml", ".codecountrc"}
+
+// Set the flags not given on the command line from the configuration
+// files, returning the languages they give for extensions
+func loadConfig() map[string]string {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	dirs := []string{}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+	if _, _, remote := codecount.ParseRemote(ROOT); !remote {
+		if info, err := os.Stat(ROOT); err == nil && info.IsDir() {
+			dirs = append(dirs, ROOT)
+		} else {
+			dirs = append(dirs, filepath.Dir(ROOT))
+		}
+	}
+
+	extensions := map[string]string{}
+	for _, dir := range dirs {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			config, err := codecount.ReadConfig(path)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				fatal("Reading the configuration failed: " + err.Error())
+			}
+			applyConfig(path, config, given, extensions)
+			break
+		}
+	}
+	return extensions
+}
+
+// Set the flags named by the keys of the configuration
+func applyConfig(path string, config codecount.Config, given map[string]bool,
+	extensions map[string]string) {
+
+	keys := []string{}
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := config[key]
+		if strings.HasPrefix(key, "languages.") {
+			extensions[strings.TrimPrefix(key, "languages.")] = strings.Join(values, "")
+			continue
+		}
+		f := flag.Lookup(key)
+		if f == nil {
+			fatalf("%s: unknown setting %q", path, key)
+		}
+		if given[key] {
+			continue
+		}
+		if _, repeated := f.Value.(*listFlag); repeated {
+			for _, value := range values {
+				f.Value.Set(value)
+			}
+		} else if err := f.Value.Set(strings.Join(values, ",")); err != nil {
+			fatalf("%s: setting %s: %s", path, key, err)
+		}
+	}
+}
+
+// Count the extensions as the languages named for them
+func mapExtensions(extensions map[string]string) {
+	langs := map[string]*codecount.Language{}
+	for ext, name := range extensions {
+		lang, found := codecount.FindLanguage(name)
+		if !found {
+			fatalf("Unknown language %q for %s", name, ext)
+		}
+		if langs[lang.Name] == nil {
+			langs[lang.Name] = &lang
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		langs[lang.Name].Extension = append(langs[lang.Name].Extension, ext)
+	}
+	for _, lang := range langs {
+		codecount.AddLanguages(codecount.Languages{*lang})
+	}
+}
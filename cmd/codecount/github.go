@@ -0,0 +1,103 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package main
+
+import (
+	"codecount"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Report to GitHub Actions: a summary of the job in Markdown, annotations
+// for the limits failed and the totals as outputs of the step
+func reportGitHub(result *codecount.Result, outcomes []codecount.Outcome) {
+	totals := result.Totals()
+	fmt.Printf("::notice title=codecount::%d lines of code in %d files\n",
+		totals.Code, totals.Files)
+	for _, outcome := range outcomes {
+		if outcome.Failure == "" {
+			continue
+		}
+		properties := "title=" + escapeProperty("codecount "+outcome.Suite)
+		if outcome.Suite == "max-file-lines" {
+			properties += ",file=" + escapeProperty(outcome.Name)
+		}
+		fmt.Printf("::error %s::%s\n", properties, escapeData("Limit failed: "+outcome.Failure))
+	}
+
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fatal("Writing the job summary failed: " + err.Error())
+		}
+		rows := reportRows(result)
+		if err := sizeColumns(rows); err != nil {
+			fatal(err)
+		}
+		fmt.Fprint(f, "## Codecount\n\n")
+		reportMarkdown(f, rows, totals)
+		failed := false
+		for _, outcome := range outcomes {
+			if outcome.Failure != "" {
+				if !failed {
+					fmt.Fprint(f, "\n### Limits failed\n\n")
+					failed = true
+				}
+				fmt.Fprintf(f, "- %s\n", outcome.Failure)
+			}
+		}
+		fmt.Fprintln(f)
+		if err := f.Close(); err != nil {
+			fatal("Writing the job summary failed: " + err.Error())
+		}
+	}
+
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fatal("Writing the step outputs failed: " + err.Error())
+		}
+		for _, name := range []string{"files", "code", "comments", "blanks", "lines"} {
+			value, _ := totals.Column(name)
+			fmt.Fprintf(f, "%s=%d\n", name, value)
+		}
+		// The code of each language as JSON, for fromJSON in a workflow
+		languages := map[string]int{}
+		for _, group := range result.ByLanguage() {
+			languages[group.Name] = group.Code
+		}
+		data, _ := json.Marshal(languages)
+		fmt.Fprintf(f, "languages=%s\n", data)
+		if err := f.Close(); err != nil {
+			fatal("Writing the step outputs failed: " + err.Error())
+		}
+	}
+}
+
+// Escape the message of a workflow command
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// Escape a property of a workflow command, like the file of an annotation
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A",
+		":", "%3A", ",", "%2C").Replace(s)
+}
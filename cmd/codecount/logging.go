@@ -0,0 +1,146 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Levels of the log, each showing those above it
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+)
+
+var levelNames = []string{"debug", "info", "warn"}
+
+// Logger writes records at or above its level to stderr, as key=value
+// text or as a JSON object a line
+type logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level int
+	json  bool
+}
+
+// The log of the run, set up from the flags before scanning
+var logs = &logger{out: os.Stderr, level: levelInfo}
+
+// Set the level and format of the log from their names
+func (l *logger) configure(level string, format string) error {
+	l.level = -1
+	for i, name := range levelNames {
+		if strings.EqualFold(level, name) {
+			l.level = i
+		}
+	}
+	if l.level < 0 {
+		return fmt.Errorf("unknown log level %q, want debug, info or warn", level)
+	}
+	switch strings.ToLower(format) {
+	case "text":
+		l.json = false
+	case "json":
+		l.json = true
+	default:
+		return fmt.Errorf("unknown log format %q, want text or json", format)
+	}
+	return nil
+}
+
+// Does the log take records of the level
+func (l *logger) enabled(level int) bool {
+	return level >= l.level
+}
+
+func (l *logger) Debug(msg string, fields ...interface{}) { l.log(levelDebug, msg, fields) }
+func (l *logger) Info(msg string, fields ...interface{})  { l.log(levelInfo, msg, fields) }
+func (l *logger) Warn(msg string, fields ...interface{})  { l.log(levelWarn, msg, fields) }
+
+// Write a record of the message and its fields, given as key and value
+// pairs
+func (l *logger) log(level int, msg string, fields []interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	pairs := [][2]string{
+		{"time", time.Now().Format(time.RFC3339)},
+		{"level", levelNames[level]},
+		{"msg", msg},
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		pairs = append(pairs, [2]string{fmt.Sprint(fields[i]), fmt.Sprint(fields[i+1])})
+	}
+
+	buf := &bytes.Buffer{}
+	for i, pair := range pairs {
+		if l.json {
+			key, _ := json.Marshal(pair[0])
+			value, _ := json.Marshal(pair[1])
+			if i == 0 {
+				buf.WriteString("{")
+			} else {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(buf, "%s:%s", key, value)
+			continue
+		}
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		value := pair[1]
+		if value == "" || strings.ContainsAny(value, " =") || strconv.Quote(value) != `"`+value+`"` {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(pair[0] + "=" + value)
+	}
+	if l.json {
+		buf.WriteString("}")
+	}
+	buf.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(buf.Bytes())
+}
+
+// A writer for the debug output of the scanner, a record for each line
+// classified and each file skipped
+type debugWriter struct {
+	log *logger
+}
+
+func (w debugWriter) Write(p []byte) (int, error) {
+	text := strings.TrimSuffix(string(p), "\n")
+	fields := strings.SplitN(text, "\t", 3)
+	if len(fields) == 3 {
+		w.log.Debug("Classified", "class", fields[0], "file", fields[1], "line", fields[2])
+	} else {
+		w.log.Debug(text)
+	}
+	return len(p), nil
+}
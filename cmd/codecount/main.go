@@ -0,0 +1,1176 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+// Command line interface for counting the lines in text type files
+// recursively though a directory.
+package main
+
+import (
+	"codecount"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const VERSION = "0.3"
+
+var (
+	ROOT         = string(".")
+	ROOTS        = []string{"."}
+	ARG_JSON     = flag.Bool("json", false, "Output JSON")
+	ARG_CSV      = flag.Bool("csv", false, "Output CSV")
+	ARG_FORMAT   = flag.String("format", "", "Output with the library reporter of the name, as text, json or csv")
+	ARG_XML      = flag.Bool("xml", false, "Output XML")
+	ARG_YAML     = flag.Bool("yaml", false, "Output YAML")
+	ARG_PROM     = flag.Bool("prometheus", false, "Output Prometheus metrics")
+	ARG_MD       = flag.Bool("markdown", false, "Output a Markdown table")
+	ARG_HTML     = flag.String("html", "", "Write an HTML report to the file")
+	ARG_VERSION  = flag.Bool("v", false, "Display Version")
+	ARG_BYFILE   = flag.Bool("f", false, "Report by File")
+	ARG_BYPATH   = flag.Bool("p", false, "Report by Path")
+	ARG_DEBUG    = flag.Bool("d", false, "Same as -log-level debug")
+	ARG_SHOWPART = flag.Bool("partial", false, "On Ctrl-C, report the files counted so far marked (partial)")
+	ARG_QUIET    = flag.Bool("quiet", false, "Show no progress on the terminal while scanning")
+	ARG_LOGLVL   = flag.String("log-level", "info", "Least level logged to stderr: debug, info or warn")
+	ARG_LOGFMT   = flag.String("log-format", "text", "Format of the log: text or json")
+	ARG_INCLUDE  = flag.Bool("i", false, "Report Duplicate Files")
+	ARG_OMIT     = flag.String("omit", "", "Omit Files by Regex Match")
+	ARG_PROFILE  = flag.String("cpuprofile", "", "Write cpu profile to file")
+	ARG_MEMORY   = flag.String("memprofile", "", "Write mem profile to file")
+
+	ARG_INVENTORY  = flag.Bool("inventory", false, "Count files and bytes only, without reading")
+	ARG_NOCOMMENT  = flag.String("no-comments-for", "", "Count non-blank lines as code for these languages")
+	ARG_COMMENT    = flag.String("comments-for", "", "Classify comments only for these languages")
+	ARG_SQL        = flag.Bool("embedded-sql", false, "Count SQL in multi-line strings as Embedded SQL")
+	ARG_TRACKED    = flag.Bool("git-tracked", false, "Count only files tracked by git")
+	ARG_GIT        = flag.Bool("git", false, "Same as -git-tracked")
+	ARG_DIRTY      = flag.Bool("git-dirty", false, "Count only files changed from git HEAD")
+	ARG_OUT        = flag.String("out", "", "File snapshot and badge write to, stdout when empty")
+	ARG_METRIC     = flag.String("metric", "code", "Column of the totals a badge shows")
+	ARG_GITDIFF    = flag.String("git-diff", "", "Count only files changed in the git range, as main...HEAD, and the lines of the diff")
+	ARG_NOIGNORE   = flag.Bool("no-gitignore", false, "Count files ignored by .gitignore")
+	ARG_LANGS      = flag.String("languages", "", "Load language definitions from a JSON file")
+	ARG_PARTIAL    = flag.Bool("count-partial", false, "Report code lines holding a comment as mixed")
+	ARG_DOCCODE    = flag.Bool("docstrings-as-code", false, "Count docstrings as code")
+	ARG_SPLIT      = flag.Bool("split-embedded", false, "Report script and style sections in their own languages")
+	ARG_SORT       = flag.String("sort", "", "Sort rows by code, lines, comments, blanks, files or name")
+	ARG_REVERSE    = flag.Bool("reverse", false, "Reverse the order of the rows")
+	ARG_TOP        = flag.Int("top", 0, "Show only the largest N rows")
+	ARG_MINLINES   = flag.Int("min-lines", 0, "Hide rows with fewer lines")
+	ARG_COLUMNS    = flag.String("columns", "", "Columns to show, as name,files,blanks,comments,docs,code,mixed,lines,complexity,functions,longest,average,bytes,size")
+	ARG_ADDR       = flag.String("addr", ":8080", "Address for serve to listen on")
+	ARG_SERVEROOT  = flag.String("root", "", "Directory served by serve, the current one when empty")
+	ARG_CACHE      = flag.Duration("cache", time.Minute, "How long serve reuses a count")
+	ARG_STREAM     = flag.Bool("stream", false, "Fold each file into the totals as counted, keeping memory flat; not for reports of files")
+	ARG_WATCH      = flag.Bool("watch", false, "Report again whenever files change")
+	ARG_POLL       = flag.Duration("poll", time.Second, "How often -watch looks for changes")
+	ARG_NOCACHE    = flag.Bool("no-cache", false, "Scan every file rather than reuse cached counts")
+	ARG_CLEAR      = flag.Bool("clear-cache", false, "Remove the cached counts before counting")
+	ARG_FILESFROM  = flag.String("files-from", "", "Count the files listed one per line in the file, - for stdin")
+	ARG_STDIN      = flag.Bool("stdin", false, "Count the content of stdin")
+	ARG_LANG       = flag.String("lang", "", "Language of the content of stdin")
+	ARG_BYROOT     = flag.Bool("group-by-root", false, "Report by each directory given")
+	ARG_MAXLINES   = flag.Int("max-file-lines", 0, "Exit with status 1 when a file has more lines")
+	ARG_GITHUB     = flag.Bool("github", false, "Report to GitHub Actions: a job summary, annotations and step outputs")
+	ARG_JUNIT      = flag.String("junit", "", "Write the limits as JUnit XML to the file, failures for those failed")
+	ARG_GENERATED  = flag.Bool("include-generated", false, "Count files written by tools in the totals")
+	ARG_VENDORED   = flag.Bool("include-vendored", false, "Count files in vendored directories in the totals")
+	ARG_COMPLEXITY = flag.Bool("complexity", false, "Count branches in the code as a rough complexity")
+	ARG_FUNCTIONS  = flag.Bool("functions", false, "Count the functions and methods defined")
+	ARG_SIZE       = flag.Bool("size", false, "Show the size of the files as 12.3K")
+	ARG_LENGTH     = flag.Bool("line-length", false, "Show the longest and average line length in characters")
+	ARG_COCOMO     = flag.Bool("cocomo", false, "Estimate the cost to develop the code with basic COCOMO")
+	ARG_RATE       = flag.Float64("cocomo-rate", 10000, "Cost of a person-month for -cocomo")
+	ARG_SYMLINKS   = flag.Bool("follow-symlinks", false, "Walk directories linked to rather than skip them")
+	ARG_VENDOR     = flag.String("vendor-dirs", strings.Join(codecount.DefaultVendorDirs, ","), "Names of vendored directories")
+	ARG_SINCE      = flag.String("since", "", "History from this date, as 2006-01-02")
+	ARG_INTERVAL   = flag.String("interval", "month", "History interval of day, week, month or year")
+)
+
+// Glob patterns to exclude and include, each flag may be repeated
+var ARG_EXCLUDE, ARG_INCLUDES listFlag
+
+// Limits on the totals failing the run when they hold
+var ARG_FAILIF listFlag
+
+// Lines changed over the range of -git-diff, reported with the counts
+var DIFF *codecount.DiffResult
+
+// Was the scan stopped before every file was counted
+var PARTIAL bool
+
+// Temporary directories, as clones of remote repositories, removed however
+// the command ends
+var temporary []string
+
+func removeTemporary() {
+	for _, dir := range temporary {
+		os.RemoveAll(dir)
+	}
+	temporary = nil
+}
+
+// Exit with the code once the temporary directories are removed, as
+// deferred calls are not run on exit
+func exit(code int) {
+	removeTemporary()
+	os.Exit(code)
+}
+
+// Log the error and exit with status 1
+func fatal(v ...interface{}) {
+	logs.Warn(fmt.Sprint(v...))
+	exit(1)
+}
+
+func fatalf(format string, v ...interface{}) {
+	logs.Warn(fmt.Sprintf(format, v...))
+	exit(1)
+}
+
+// Depth of directories to report by, none when 0
+var ARG_BYDIR depthFlag
+
+// Size in bytes of the largest file counted, no limit when 0
+var ARG_MAXSIZE sizeFlag
+
+func init() {
+	flag.Var(&ARG_EXCLUDE, "exclude", "Skip paths matching the glob, may be repeated")
+	flag.Var(&ARG_INCLUDES, "include", "Count only files matching the glob, may be repeated")
+	flag.Var(&ARG_BYDIR, "by-dir", "Report by Directory, to the depth given as -by-dir=N")
+	flag.StringVar(ARG_OUT, "o", "", "Same as -out")
+	flag.Var(&ARG_MAXSIZE, "max-file-size", "Skip files larger than the size, as 10MB")
+	flag.Var(&ARG_FAILIF, "fail-if", "Exit with status 1 when the totals meet the limit, as 'code > 100000'")
+}
+
+// A flag taking a depth, alone it is a depth of 1
+type depthFlag int
+
+func (d *depthFlag) String() string   { return strconv.Itoa(int(*d)) }
+func (d *depthFlag) IsBoolFlag() bool { return true }
+func (d *depthFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*d = 1
+	case "false":
+		*d = 0
+	default:
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 0 {
+			return fmt.Errorf("depth %q is not a number", value)
+		}
+		*d = depthFlag(depth)
+	}
+	return nil
+}
+
+// A flag taking a size in bytes with an optional unit of KB, MB or GB
+type sizeFlag int64
+
+func (f *sizeFlag) String() string { return strconv.FormatInt(int64(*f), 10) }
+func (f *sizeFlag) Set(value string) error {
+	number, unit := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, suffix := range []struct {
+		name string
+		size int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(number, suffix.name) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, suffix.name)), suffix.size
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("size %q is not a number of bytes, KB, MB or GB", value)
+	}
+	*f = sizeFlag(size * float64(unit))
+	return nil
+}
+
+// A flag collecting each value when given more than once
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Run the codecounter
+func main() {
+	defer removeTemporary()
+	start := time.Now()
+	flag.Parse()
+	args := flag.Args()
+
+	// Subcommands take their own arguments after the flags
+	command := ""
+	if len(args) > 0 && (args[0] == "diff" || args[0] == "history" ||
+		args[0] == "serve" || args[0] == "snapshot" || args[0] == "compare" || args[0] == "badge") {
+
+		command = args[0]
+		flag.CommandLine.Parse(args[1:])
+		args = flag.Args()
+	}
+
+	// Compare takes the snapshot before the roots
+	baseline := ""
+	if command == "compare" {
+		if len(args) == 0 {
+			fatal("compare needs a snapshot to compare to")
+		}
+		baseline, args = args[0], args[1:]
+	}
+	if len(args) > 0 {
+		ROOT = args[0]
+		ROOTS = args
+	}
+	extensions := loadConfig()
+
+	if *ARG_VERSION {
+		fmt.Printf("Codecount %s\n", VERSION)
+		return
+	}
+
+	if *ARG_PROFILE != "" {
+		f, err := os.Create(*ARG_PROFILE)
+		if err != nil {
+			fatal(err)
+		}
+		pprof.StartCPUProfile(f)
+		defer pprof.StopCPUProfile()
+	}
+
+	// Languages from the user config, then from the command line
+	if home, err := os.UserHomeDir(); err == nil {
+		loadLanguages(filepath.Join(home, ".config", "codecount", "languages.json"), false)
+	}
+	if *ARG_LANGS != "" {
+		loadLanguages(*ARG_LANGS, true)
+	}
+	mapExtensions(extensions)
+
+	scanner := codecount.Scanner{
+		NoComments:       parseList(*ARG_NOCOMMENT),
+		OnlyComments:     parseList(*ARG_COMMENT),
+		EmbeddedSQL:      *ARG_SQL,
+		CountPartial:     *ARG_PARTIAL,
+		DocStringsAsCode: *ARG_DOCCODE,
+		SplitEmbedded:    *ARG_SPLIT,
+		IncludeGenerated: *ARG_GENERATED,
+		IncludeVendored:  *ARG_VENDORED,
+		FollowSymlinks:   *ARG_SYMLINKS,
+		Complexity:       *ARG_COMPLEXITY,
+		Functions:        *ARG_FUNCTIONS,
+		MaxFileSize:      int64(ARG_MAXSIZE),
+		VendorDirs:       strings.Split(*ARG_VENDOR, ","),
+		Inventory:        *ARG_INVENTORY,
+		NoGitignore:      *ARG_NOIGNORE,
+		Exclude:          ARG_EXCLUDE,
+		Include:          ARG_INCLUDES,
+	}
+	if *ARG_DEBUG {
+		*ARG_LOGLVL = "debug"
+	}
+	if err := logs.configure(*ARG_LOGLVL, *ARG_LOGFMT); err != nil {
+		fatal(err)
+	}
+	if logs.enabled(levelDebug) {
+		scanner.Debug = debugWriter{logs}
+	}
+	if *ARG_OMIT != "" {
+		var err error
+		scanner.Omit, err = regexp.Compile(*ARG_OMIT)
+		if err != nil {
+			fatal("Omit regex failed to parse: " + err.Error())
+		}
+	}
+
+	if command == "diff" {
+		runDiff(&scanner, args, start)
+		return
+	} else if command == "history" {
+		runHistory(&scanner, start)
+		return
+	} else if command == "serve" {
+		runServe(&scanner)
+		return
+	}
+
+	// Remote repositories are counted from temporary clones, named by
+	// their url when reporting by root
+	remotes := map[string]string{}
+	for i, root := range ROOTS {
+		url, ref, ok := codecount.ParseRemote(root)
+		if !ok {
+			continue
+		}
+		dir, err := codecount.GitClone(url, ref)
+		if err != nil {
+			fatal("Cloning failed: " + err.Error())
+		}
+		temporary = append(temporary, dir)
+		remotes[dir] = root
+		ROOTS[i] = dir
+		*ARG_NOCACHE = true
+	}
+	// Paths of a single repository are reported within it
+	if len(ROOTS) == 1 && len(remotes) == 1 {
+		if err := os.Chdir(ROOTS[0]); err != nil {
+			fatal(err)
+		}
+		remotes["."] = remotes[ROOTS[0]]
+		ROOTS[0] = "."
+	}
+	ROOT = ROOTS[0]
+
+	// Git has already applied the ignore files to the files it lists
+	if *ARG_DIRTY {
+		var err error
+		scanner.Only, err = gitFiles(codecount.GitDirty)
+		if err != nil {
+			fatal("Listing changed files failed: " + err.Error())
+		}
+		scanner.NoGitignore = true
+	} else if *ARG_GITDIFF != "" {
+		var err error
+		scanner.Only, err = gitFiles(func(root string) (map[string]bool, error) {
+			return codecount.GitChanged(root, *ARG_GITDIFF)
+		})
+		if err != nil {
+			fatal("Listing changed files failed: " + err.Error())
+		}
+		scanner.NoGitignore = true
+	} else if *ARG_TRACKED || *ARG_GIT {
+		var err error
+		scanner.Only, err = gitFiles(codecount.GitTracked)
+		if err != nil {
+			fatal("Listing tracked files failed: " + err.Error())
+		}
+		scanner.NoGitignore = true
+	}
+
+	checks := []codecount.Check{}
+	for _, expr := range ARG_FAILIF {
+		check, err := codecount.ParseCheck(expr)
+		if err != nil {
+			fatal(err)
+		}
+		checks = append(checks, check)
+	}
+
+	// Collect the files or single file
+	scanner.Stream = *ARG_STREAM && !reportsFiles(command)
+	openCache(&scanner)
+	var shown *progress
+	if width := terminalSize(os.Stderr); width > 0 && !*ARG_QUIET && !*ARG_STDIN &&
+		!logs.enabled(levelDebug) {
+
+		shown = newProgress(os.Stderr, width)
+		scanner.Progress = shown.add
+	}
+	// Ctrl-C stops the scan, reporting what was counted when asked to
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			cancel()
+		}
+	}()
+	scanner.Context = ctx
+	var result *codecount.Result
+	var err error
+	if *ARG_STDIN {
+		lang, found := codecount.FindLanguage(*ARG_LANG)
+		if !found && *ARG_LANG != "" {
+			fatalf("Unknown language %q", *ARG_LANG)
+		}
+		result, err = scanner.ScanReader(os.Stdin, "stdin", lang)
+		if err == nil && len(result.Files) == 0 {
+			fatal("The language of stdin is unknown, give it with -lang")
+		}
+	} else if *ARG_FILESFROM != "" {
+		result, err = scanner.ScanFiles(readFileList(*ARG_FILESFROM))
+	} else {
+		result, err = scanner.ScanAll(ROOTS)
+	}
+	if shown != nil {
+		shown.done()
+		scanner.Progress = nil
+	}
+	signal.Stop(interrupt)
+	close(interrupt)
+	scanner.Context = nil
+	if result != nil && result.Partial {
+		saveCache(&scanner)
+		if *ARG_SHOWPART {
+			PARTIAL = true
+			reportResult(result, start)
+		} else {
+			logs.Warn("Interrupted")
+		}
+		exit(130)
+	}
+	cancel()
+	if err != nil {
+		fatal(err)
+	}
+	logs.Debug("Scanned", "files", len(result.Files), "runtime", time.Now().Sub(start))
+	for i := range result.Files {
+		if url, found := remotes[result.Files[i].Root]; found {
+			result.Files[i].Root = url
+		}
+	}
+	saveCache(&scanner)
+	if *ARG_GITDIFF != "" {
+		if DIFF, err = scanner.GitDiff(ROOT, *ARG_GITDIFF); err != nil {
+			fatal("Diffing the range failed: " + err.Error())
+		}
+	}
+
+	if command == "snapshot" {
+		writeSnapshot(result, start)
+		return
+	} else if command == "badge" {
+		badge, err := codecount.BadgeFor(result.Totals(), *ARG_METRIC)
+		if err != nil {
+			fatal(err)
+		}
+		writeOut(badge)
+		return
+	} else if command == "compare" {
+		runCompare(result, baseline, start)
+		return
+	}
+
+	reportResult(result, start)
+	outcomes := result.CheckLimits(checks, *ARG_MAXLINES)
+	if *ARG_GITHUB {
+		reportGitHub(result, outcomes)
+	}
+	if failed := checkLimits(outcomes); failed {
+		exit(1)
+	}
+	if *ARG_WATCH {
+		runWatch(&scanner, result)
+	}
+
+	if *ARG_MEMORY != "" {
+		f, err := os.Create(*ARG_MEMORY)
+		if err != nil {
+			fatal(err)
+		}
+		pprof.WriteHeapProfile(f)
+		f.Close()
+		return
+	}
+}
+
+// Does the report need each file rather than the totals, so files cannot
+// be folded in as they are counted
+func reportsFiles(command string) bool {
+	return command == "snapshot" || command == "compare" || *ARG_BYFILE || *ARG_BYPATH ||
+		*ARG_BYROOT || ARG_BYDIR > 0 || *ARG_JSON || *ARG_XML || *ARG_YAML || *ARG_HTML != "" ||
+		*ARG_INVENTORY || *ARG_INCLUDE || *ARG_WATCH || *ARG_MAXLINES > 0 ||
+		strings.EqualFold(*ARG_FORMAT, "json")
+}
+
+// Tell of the limits the result fails in the log, and in JUnit XML when
+// asked, true when there are any
+func checkLimits(outcomes []codecount.Outcome) bool {
+	failed := false
+	for _, outcome := range outcomes {
+		if outcome.Failure != "" {
+			logs.Warn("Limit failed", "failure", outcome.Failure)
+			failed = true
+		}
+	}
+	if *ARG_JUNIT != "" {
+		f, err := os.Create(*ARG_JUNIT)
+		if err != nil {
+			fatal(err)
+		}
+		if err := codecount.WriteJUnit(f, outcomes); err != nil {
+			fatal("Writing the JUnit report failed: " + err.Error())
+		}
+		f.Close()
+	}
+	return failed
+}
+
+// The files git lists for each of the roots
+func gitFiles(list func(root string) (map[string]bool, error)) (map[string]bool, error) {
+	files := map[string]bool{}
+	for _, root := range ROOTS {
+		listed, err := list(root)
+		if err != nil {
+			return nil, err
+		}
+		for name := range listed {
+			files[name] = true
+		}
+	}
+	return files, nil
+}
+
+// The paths listed one per line in the file or stdin for -
+func readFileList(name string) []string {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		fatal("Reading the list of files failed: " + err.Error())
+	}
+	paths := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths
+}
+
+// Reuse the counts of unchanged files from earlier runs, a cache that
+// cannot be read only means every file is scanned
+func openCache(scanner *codecount.Scanner) {
+	path, err := codecount.DefaultCachePath()
+	if err != nil {
+		return
+	}
+	cache, err := codecount.OpenCache(path)
+	if err != nil {
+		logs.Warn("Reading the cache failed", "error", err)
+		return
+	}
+	if *ARG_CLEAR {
+		if err := cache.Clear(); err != nil {
+			fatal("Clearing the cache failed: " + err.Error())
+		}
+	}
+	// Debug logs show the lines of the files scanned, and a cache holds
+	// an entry for every file where streaming keeps none
+	if !*ARG_NOCACHE && !*ARG_INVENTORY && !scanner.Stream && scanner.Debug == nil {
+		scanner.Cache = cache
+	}
+}
+
+func saveCache(scanner *codecount.Scanner) {
+	if scanner.Cache == nil {
+		return
+	}
+	if err := scanner.Cache.Save(); err != nil {
+		logs.Warn("Writing the cache failed", "error", err)
+	}
+}
+
+// Print the result in the format asked for
+func reportResult(result *codecount.Result, start time.Time) {
+	if *ARG_HTML != "" {
+		f, err := os.Create(*ARG_HTML)
+		if err != nil {
+			fatal(err)
+		}
+		err = result.WriteHTML(f, ROOT)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fatal("Writing the HTML report failed: " + err.Error())
+		}
+		fmt.Println("Runtime: ", time.Now().Sub(start))
+	} else if *ARG_FORMAT != "" {
+		writeReport(*ARG_FORMAT, result, start)
+	} else if *ARG_JSON {
+		reportJSON(result, time.Now().Sub(start))
+	} else if *ARG_XML {
+		report := buildReport(result, time.Now().Sub(start))
+		fmt.Print(xml.Header)
+		e := xml.NewEncoder(os.Stdout)
+		e.Indent("", "  ")
+		if err := e.Encode(report); err != nil {
+			fatal(err)
+		}
+		fmt.Println()
+	} else if *ARG_YAML {
+		report := buildReport(result, time.Now().Sub(start))
+		if err := report.WriteYAML(os.Stdout); err != nil {
+			fatal(err)
+		}
+	} else if *ARG_PROM {
+		// Every language is a series of its own, none trimmed into a rest row
+		if err := result.Report(time.Now().Sub(start)).WritePrometheus(os.Stdout); err != nil {
+			fatal(err)
+		}
+	} else if *ARG_CSV {
+		reportCSV(os.Stdout, reportRows(result))
+	} else if *ARG_MD {
+		rows := reportRows(result)
+		if err := sizeColumns(rows); err != nil {
+			fatal(err)
+		}
+		reportMarkdown(os.Stdout, rows, result.Totals())
+	} else if *ARG_INVENTORY {
+		reportInventory(reportRows(result), result.Totals())
+		fmt.Println("Runtime: ", time.Now().Sub(start))
+	} else {
+		reportText(result, time.Now().Sub(start))
+	}
+}
+
+// Write the result to stdout with the reporter registered by the name
+func writeReport(name string, result *codecount.Result, start time.Time) {
+	reporter, err := codecount.NewReporter(name, os.Stdout)
+	if err != nil {
+		fatal(err)
+	}
+	if err := result.Write(reporter, time.Now().Sub(start)); err != nil {
+		fatal(err)
+	}
+}
+
+// Print the result as a table of the rows and the totals, then the files
+// kept out of the totals
+func reportText(result *codecount.Result, runtime time.Duration) {
+	rows := reportRows(result)
+	if err := sizeColumns(rows); err != nil {
+		fatal(err)
+	}
+	reportHeader()
+	reportDetail(rows)
+
+	totals := result.Totals()
+	reportRule()
+	reportLine("Totals", totals)
+	reportRule()
+	if empty := result.EmptyTotals(); empty.Files > 0 {
+		reportLine("Empty (included)", empty)
+		reportRule()
+	}
+	if generated := result.GeneratedTotals(); generated.Files > 0 {
+		reportLine("Generated (excluded)", generated)
+		reportRule()
+	}
+	if vendored := result.VendoredTotals(); vendored.Files > 0 {
+		reportLine("Vendored (excluded)", vendored)
+		reportRule()
+	}
+	if binaries := result.Binaries(); len(binaries) > 0 {
+		reportLine("Skipped binary", codecount.Count{Files: len(binaries)})
+		reportRule()
+	}
+	if invalid := result.Invalid(); len(invalid) > 0 {
+		reportLine("Skipped invalid", codecount.Count{Files: len(invalid)})
+		reportRule()
+	}
+	if large := result.Large(); len(large) > 0 {
+		reportLine("Skipped large", codecount.Count{Files: len(large)})
+		reportRule()
+	}
+	if result.Links > 0 {
+		reportLine("Skipped links", codecount.Count{Files: result.Links})
+		reportRule()
+	}
+	if *ARG_INCLUDE {
+		reportDuplicates(result.Duplicates())
+	}
+	if *ARG_COCOMO {
+		reportCocomo(totals)
+	}
+	if DIFF != nil {
+		fmt.Println()
+		reportDiff(DIFF, *ARG_GITDIFF)
+	}
+	fmt.Println("Runtime: ", runtime)
+}
+
+// Scan the root again whenever files change, reporting again or as JSON
+// the change to the totals
+func runWatch(scanner *codecount.Scanner, result *codecount.Result) {
+	for {
+		time.Sleep(*ARG_POLL)
+		start := time.Now()
+		next, err := scanner.Rescan(ROOTS, result)
+		if err != nil {
+			logs.Warn("Scanning again failed", "error", err)
+			continue
+		}
+		saveCache(scanner)
+		changed := next.Changed(result)
+		if len(changed) == 0 {
+			continue
+		}
+
+		if *ARG_JSON {
+			before, delta := result.Totals(), next.Totals()
+			delta.Sub(before)
+			delta.Files -= before.Files
+			json.NewEncoder(os.Stdout).Encode(struct {
+				Time    time.Time       `json:"time"`
+				Changed []string        `json:"changed"`
+				Delta   codecount.Count `json:"delta"`
+				Totals  codecount.Count `json:"totals"`
+			}{start, changed, delta, next.Totals()})
+		} else {
+			fmt.Println()
+			reportResult(next, start)
+		}
+		result = next
+	}
+}
+
+// Compare two directories, or two git revisions of the root
+func runDiff(scanner *codecount.Scanner, args []string, start time.Time) {
+	if len(args) != 2 {
+		fatal("diff needs an old and a new directory or git revision")
+	}
+	older, newer := args[0], args[1]
+	if *ARG_GIT {
+		var err error
+		if older, err = codecount.GitExport(".", args[0]); err != nil {
+			fatal(err)
+		}
+		temporary = append(temporary, older)
+		if newer, err = codecount.GitExport(".", args[1]); err != nil {
+			fatal(err)
+		}
+		temporary = append(temporary, newer)
+	}
+
+	diff, err := scanner.Diff(older, newer)
+	if err != nil {
+		fatal(err)
+	}
+	if *ARG_JSON {
+		json.NewEncoder(os.Stdout).Encode(diff)
+		return
+	}
+
+	reportDiff(diff, args[0]+" to "+args[1])
+	fmt.Println("Runtime: ", time.Now().Sub(start))
+}
+
+// Print the lines added and removed by their classification
+func reportDiff(diff *codecount.DiffResult, title string) {
+	rows := diff.ByLanguage
+	if *ARG_BYFILE {
+		rows = diff.Files
+	}
+	fmt.Printf("Codecount - v %s - %s\n", VERSION, title)
+	fmt.Println(strings.Repeat("-", 79))
+	fmt.Printf("%-29s%8s%8s%8s%8s%8s%8s\n",
+		"Grouping", "Blank+", "Blank-", "Comm+", "Comm-", "Code+", "Code-")
+	fmt.Println(strings.Repeat("-", 79))
+	for _, row := range append(rows, diff.Totals) {
+		if row.Name == "Totals" {
+			fmt.Println(strings.Repeat("-", 79))
+		}
+		name := row.Name
+		if len(name) > 29 {
+			name = name[0:10] + "..." + name[len(name)-16:]
+		}
+		fmt.Printf("%-29s%8d%8d%8d%8d%8d%8d\n",
+			name,
+			row.Added.Blanks,
+			row.Removed.Blanks,
+			row.Added.Comments,
+			row.Removed.Comments,
+			row.Added.Code,
+			row.Removed.Code)
+	}
+	fmt.Println(strings.Repeat("-", 79))
+}
+
+// Write the JSON report of the result to keep as a baseline
+func writeSnapshot(result *codecount.Result, start time.Time) {
+	data, err := json.MarshalIndent(result.Report(time.Now().Sub(start)), "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	writeOut(append(data, '\n'))
+}
+
+// Write the output of a subcommand to the -out file or stdout
+func writeOut(data []byte) {
+	if *ARG_OUT == "" {
+		os.Stdout.Write(data)
+	} else if err := ioutil.WriteFile(*ARG_OUT, data, 0644); err != nil {
+		fatal("Writing " + *ARG_OUT + " failed: " + err.Error())
+	}
+}
+
+// Compare the result to a snapshot, by language and the files whose code
+// moved the most
+func runCompare(result *codecount.Result, baseline string, start time.Time) {
+	before, err := codecount.ReadReport(baseline)
+	if err != nil {
+		fatal("Reading the snapshot failed: " + err.Error())
+	}
+	comparison := codecount.CompareReports(before, result.Report(0))
+	if *ARG_JSON {
+		json.NewEncoder(os.Stdout).Encode(comparison)
+		return
+	}
+
+	top := *ARG_TOP
+	if top <= 0 {
+		top = 10
+	}
+	movers := comparison.Files
+	if len(movers) > top {
+		movers = movers[:top]
+	}
+	fmt.Printf("Codecount - v %s - compared to %s\n", VERSION, baseline)
+	fmt.Println(strings.Repeat("-", 79))
+	fmt.Printf("%-29s%10s%10s%10s%10s%10s\n",
+		"Grouping", "Files", "Code was", "Code now", "Code +/-", "Lines +/-")
+	fmt.Println(strings.Repeat("-", 79))
+	line := func(d codecount.Delta) {
+		net := d.Net()
+		fmt.Printf("%-29s%+10d%10d%10d%+10d%+10d\n", fitName(d.Name),
+			net.Files, d.Before.Code, d.After.Code, net.Code, net.Lines)
+	}
+	for _, d := range comparison.ByLanguage {
+		line(d)
+	}
+	fmt.Println(strings.Repeat("-", 79))
+	line(comparison.Totals)
+	fmt.Println(strings.Repeat("-", 79))
+	if len(movers) > 0 {
+		fmt.Println("Biggest movers")
+		fmt.Println(strings.Repeat("-", 79))
+		for _, d := range movers {
+			line(d)
+		}
+		fmt.Println(strings.Repeat("-", 79))
+	}
+	fmt.Println("Runtime: ", time.Now().Sub(start))
+}
+
+// Answer counts over HTTP until stopped
+func runServe(scanner *codecount.Scanner) {
+	root := *ARG_SERVEROOT
+	if root == "" {
+		root = ROOT
+	}
+	server := &codecount.Server{Scanner: scanner, Root: root, MaxAge: *ARG_CACHE}
+	logs.Info("Serving counts", "root", root, "addr", *ARG_ADDR)
+	fatal(http.ListenAndServe(*ARG_ADDR, server))
+}
+
+// Count the root at each interval since a date through its git history
+func runHistory(scanner *codecount.Scanner, start time.Time) {
+	since, err := time.ParseInLocation("2006-01-02", *ARG_SINCE, time.Local)
+	if err != nil {
+		fatal("history needs -since as a date like 2006-01-02")
+	}
+	dates := []time.Time{}
+	for date := since; date.Before(start); {
+		dates = append(dates, date)
+		switch *ARG_INTERVAL {
+		case "day":
+			date = date.AddDate(0, 0, 1)
+		case "week":
+			date = date.AddDate(0, 0, 7)
+		case "month":
+			date = date.AddDate(0, 1, 0)
+		case "year":
+			date = date.AddDate(1, 0, 0)
+		default:
+			fatal("Unknown interval " + *ARG_INTERVAL)
+		}
+	}
+	dates = append(dates, start)
+
+	snapshots, err := scanner.History(ROOT, dates)
+	if err != nil {
+		fatal(err)
+	}
+	if *ARG_JSON {
+		json.NewEncoder(os.Stdout).Encode(snapshots)
+		return
+	} else if *ARG_CSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"Date", "Commit", "Language",
+			"Files", "Blank", "Comment", "Code", "Lines"})
+		for _, snapshot := range snapshots {
+			for _, row := range snapshot.ByLanguage {
+				w.Write([]string{
+					snapshot.Date.Format("2006-01-02"),
+					snapshot.Commit,
+					row.Name,
+					strconv.Itoa(row.Files),
+					strconv.Itoa(row.Blanks),
+					strconv.Itoa(row.Comments),
+					strconv.Itoa(row.Code),
+					strconv.Itoa(row.Lines)})
+			}
+		}
+		w.Flush()
+		return
+	}
+
+	if err := sizeColumns(nil); err != nil {
+		fatal(err)
+	}
+	reportHeader()
+	for _, snapshot := range snapshots {
+		reportLine(snapshot.Date.Format("2006-01-02")+" "+snapshot.Commit[:10],
+			snapshot.Totals)
+	}
+	reportRule()
+	fmt.Println("Runtime: ", time.Now().Sub(start))
+}
+
+// Add the languages defined in a file, a missing file is only an
+// error when it was asked for
+func loadLanguages(path string, required bool) {
+	langs, err := codecount.ReadLanguages(path)
+	if os.IsNotExist(err) && !required {
+		return
+	}
+	if err != nil {
+		fatal("Languages failed to load: " + err.Error())
+	}
+	codecount.AddLanguages(langs)
+}
+
+// Parse a comma separated list of language names into a set
+func parseList(list string) map[string]bool {
+	if list == "" {
+		return nil
+	}
+	set := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		set[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return set
+}
+
+// Print the result as the JSON report
+func reportJSON(result *codecount.Result, runtime time.Duration) {
+	json.NewEncoder(os.Stdout).Encode(buildReport(result, runtime))
+}
+
+// The report for encoding with its languages ordered and trimmed as asked
+func buildReport(result *codecount.Result, runtime time.Duration) codecount.Report {
+	report := result.Report(runtime)
+	report.Diff = DIFF
+	sortRows(report.ByLanguage)
+	report.ByLanguage = codecount.TrimGroups(report.ByLanguage, *ARG_TOP, *ARG_MINLINES)
+	return report
+}
+
+// The rows of the report for the grouping asked for
+func reportRows(result *codecount.Result) []codecount.Group {
+	rows := result.ByLanguage()
+	if *ARG_BYFILE {
+		rows = result.ByFile()
+	} else if *ARG_BYROOT {
+		rows = result.ByRoot()
+	} else if *ARG_BYPATH {
+		rows = result.ByPath()
+	} else if ARG_BYDIR > 0 {
+		rows = result.ByDir(ROOT, int(ARG_BYDIR))
+	}
+	sortRows(rows)
+	return codecount.TrimGroups(rows, *ARG_TOP, *ARG_MINLINES)
+}
+
+// Order the rows as asked, leaving the order of the grouping otherwise
+func sortRows(rows []codecount.Group) {
+	if *ARG_SORT == "" && !*ARG_REVERSE {
+		return
+	}
+	by := *ARG_SORT
+	if by == "" {
+		by = "name"
+		if *ARG_BYFILE {
+			by = "lines"
+		}
+	}
+	if err := codecount.SortGroups(rows, by, *ARG_REVERSE); err != nil {
+		fatal(err)
+	}
+}
+
+// Print the report
+func reportDetail(rows []codecount.Group) {
+	for _, row := range rows {
+		name := row.Name
+		if *ARG_BYFILE {
+			name = filepath.Base(name)
+		}
+		reportLine(fitName(name), row.Count)
+	}
+}
+
+// The titles of the CSV header kept from before the columns could be
+// chosen, where the text report shortens them to fit
+var csvTitles = map[string]string{"Complex": "Complexity", "Funcs": "Functions"}
+
+// Write the rows as CSV with a header row, the columns those of the text
+// report and each a plain number
+func reportCSV(w io.Writer, rows []codecount.Group) {
+	if err := sizeColumns(rows); err != nil {
+		fatal(err)
+	}
+	out := csv.NewWriter(w)
+	header := []string{"Grouping"}
+	for _, col := range layout.Columns {
+		title := col.Title
+		if long, ok := csvTitles[title]; ok {
+			title = long
+		}
+		header = append(header, title)
+	}
+	out.Write(header)
+	for _, row := range rows {
+		record := []string{row.Name}
+		for _, col := range layout.Columns {
+			record = append(record, strconv.Itoa(col.Value(row.Count)))
+		}
+		out.Write(record)
+	}
+	out.Flush()
+}
+
+// Write the rows and totals as a Markdown table
+func reportMarkdown(w io.Writer, rows []codecount.Group, totals codecount.Count) {
+	header, align := "| Grouping |", "| :--- |"
+	for _, col := range layout.Columns {
+		header += " " + col.Title + " |"
+		align += " ---: |"
+	}
+	fmt.Fprintln(w, header)
+	fmt.Fprintln(w, align)
+	line := func(name string, count codecount.Count, format string) {
+		fmt.Fprint(w, "| "+fmt.Sprintf(format, strings.Replace(name, "|", "\\|", -1))+" |")
+		for _, col := range layout.Columns {
+			fmt.Fprintf(w, " "+format+" |", col.text(count))
+		}
+		fmt.Fprintln(w)
+	}
+	for _, row := range rows {
+		line(row.Name, row.Count, "%s")
+	}
+	line("Totals", totals, "**%s**")
+}
+
+// Print the files whose content repeats another counted, with their totals
+func reportDuplicates(files codecount.Files) {
+	if len(files) == 0 {
+		return
+	}
+	totals := codecount.Count{}
+	for i := 0; i < len(files); i++ {
+		path := files[i].Path
+		if len(path) > layout.NameWidth {
+			head := (layout.NameWidth + 1) / 3
+			path = path[0:head] + "..." + path[len(path)-(layout.NameWidth-head-3):]
+		}
+		count := codecount.Count{Files: 1, Blanks: files[i].Blanks,
+			Comments: files[i].Comments, Code: files[i].Code,
+			Lines: files[i].Lines, Mixed: files[i].Mixed, Docs: files[i].Docs,
+			Complexity: files[i].Complexity, Functions: files[i].Functions, Longest: files[i].Longest,
+			Chars: files[i].Chars}
+		reportLine(path, count)
+		totals.Add(count)
+	}
+	reportRule()
+	reportLine("Duplicates", totals)
+	reportRule()
+}
+
+func reportHeader() {
+	if PARTIAL {
+		fmt.Printf("Codecount - v %s (partial)\n", VERSION)
+	} else {
+		fmt.Printf("Codecount - v %s\n", VERSION)
+	}
+	reportRule()
+	fmt.Printf("%-*s", layout.NameWidth, "Grouping")
+	for _, col := range layout.Columns {
+		fmt.Printf("%*s", layout.Width, col.Title)
+	}
+	fmt.Println()
+	reportRule()
+}
+
+// Print a row of the report, with the mixed lines when counted
+func reportLine(name string, count codecount.Count) {
+	fmt.Printf("%-*s", layout.NameWidth, name)
+	for _, col := range layout.Columns {
+		fmt.Printf("%*s", layout.Width, col.text(count))
+	}
+	fmt.Println()
+}
+
+// Print the basic COCOMO estimate of developing the code of the totals
+func reportCocomo(totals codecount.Count) {
+	estimate := codecount.EstimateCost(totals.Code, *ARG_RATE)
+	fmt.Printf("Estimated Cost to Develop   $%s\n", groupDigits(int64(math.Round(estimate.Cost))))
+	fmt.Printf("Estimated Schedule Effort   %.2f months\n", estimate.Schedule)
+	fmt.Printf("Estimated People Required   %.2f\n", estimate.People)
+	reportRule()
+}
+
+// Format the number with commas between groups of three digits
+func groupDigits(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	for i := len(digits) - 3; i > 0 && digits[i-1] != '-'; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
+
+// Print the inventory of files and bytes in the rows of the grouping
+func reportInventory(rows []codecount.Group, totals codecount.Count) {
+	fmt.Printf("Codecount - v %s\n", VERSION)
+	fmt.Println(strings.Repeat("-", 79))
+	fmt.Printf("%-29s%10s%20s\n", "Grouping", "Files", "Bytes")
+	fmt.Println(strings.Repeat("-", 79))
+	for _, row := range rows {
+		name := row.Name
+		if *ARG_BYFILE {
+			name = filepath.Base(name)
+		}
+		fmt.Printf("%-29s%10d%20d\n", fitName(name), row.Files, row.Bytes)
+	}
+	fmt.Println(strings.Repeat("-", 79))
+	fmt.Printf("%-29s%10d%20d\n", "Totals", totals.Files, totals.Bytes)
+	fmt.Println(strings.Repeat("-", 79))
+}
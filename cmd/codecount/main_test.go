@@ -0,0 +1,327 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package main
+
+import (
+	"bytes"
+	"codecount"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Set a flag for a test, returning a func to set it back
+func setFlag(t *testing.T, name string, value string) func() {
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() { flag.Set(name, old) }
+}
+
+// Set a variable of the environment for a test, returning a func to set
+// it back
+func setEnv(name string, value string) func() {
+	old, found := os.LookupEnv(name)
+	os.Setenv(name, value)
+	return func() {
+		if found {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// The output of the func to stdout
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		done <- string(out)
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+var testRows = []codecount.Group{
+	{Name: "Go", Count: codecount.Count{Files: 2, Blanks: 1, Comments: 2, Code: 10,
+		Lines: 13, Docs: 1, Complexity: 4, Bytes: 2048}},
+	{Name: "a,b.go", Count: codecount.Count{Files: 1, Code: 1, Lines: 1, Bytes: 10}},
+}
+
+func TestReportCSV(t *testing.T) {
+	tests := []struct {
+		flag, value string
+		want        string
+	}{
+		{"columns", "", "Grouping,Files,Blank,Comment,Code,Lines\n" +
+			"Go,2,1,2,10,13\n\"a,b.go\",1,0,0,1,1\n"},
+		{"complexity", "true", "Grouping,Files,Blank,Comment,Code,Lines,Complexity\n" +
+			"Go,2,1,2,10,13,4\n\"a,b.go\",1,0,0,1,1,0\n"},
+		{"columns", "name,files,docs,bytes,size", "Grouping,Files,Docs,Bytes,Size\n" +
+			"Go,2,1,2048,2048\n\"a,b.go\",1,0,10,10\n"},
+	}
+	for _, test := range tests {
+		restore := setFlag(t, test.flag, test.value)
+		out := &bytes.Buffer{}
+		reportCSV(out, testRows)
+		restore()
+		if out.String() != test.want {
+			t.Errorf("-%s=%s: CSV %q, want %q", test.flag, test.value, out.String(), test.want)
+		}
+	}
+}
+
+func TestSizeColumns(t *testing.T) {
+	defer setEnv("COLUMNS", "60")()
+	if err := sizeColumns(testRows); err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.Columns) != 5 || layout.Width != 10 || layout.NameWidth != 29 {
+		t.Errorf("Default layout of %d columns %d wide and names %d wide, want 5, 10 and 29",
+			len(layout.Columns), layout.Width, layout.NameWidth)
+	}
+
+	// A long name takes the width left by the columns and no more
+	long := strings.Repeat("dir/", 25) + "main.go"
+	os.Setenv("COLUMNS", "120")
+	if err := sizeColumns([]codecount.Group{{Name: long}}); err != nil {
+		t.Fatal(err)
+	}
+	if layout.NameWidth != 69 {
+		t.Errorf("Name width %d in 120 columns, want 69", layout.NameWidth)
+	}
+	if name := fitName(long); len(name) != 69 || !strings.Contains(name, "...") ||
+		!strings.HasSuffix(name, "/main.go") {
+
+		t.Errorf("Fitted name %q, want 69 characters ending in the file", name)
+	}
+	if name := fitName("main.go"); name != "main.go" {
+		t.Errorf("Fitted name %q, want it unchanged", name)
+	}
+
+	restore := setFlag(t, "columns", "name,file,blanks,comments,code,docs,lines")
+	err := sizeColumns(testRows)
+	restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.Columns) != 6 || layout.Width != 8 || layout.Columns[0].Title != "Files" {
+		t.Errorf("Layout of %d columns %d wide, want 6 and 8 with files first",
+			len(layout.Columns), layout.Width)
+	}
+
+	defer setFlag(t, "columns", "files,lnes")()
+	if err := sizeColumns(testRows); err == nil || !strings.Contains(err.Error(), `"lnes"`) {
+		t.Errorf("Unknown column gave %v, want an error naming it", err)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for size, want := range map[int64]string{
+		512:             "512",
+		1536:            "1.5K",
+		3 << 20:         "3.0M",
+		5<<30 + 512<<20: "5.5G",
+	} {
+		if got := humanSize(size); got != want {
+			t.Errorf("humanSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	rows := []codecount.Group{{Name: "a|b", Count: codecount.Count{Files: 1, Blanks: 1, Code: 3, Lines: 4}}}
+	if err := sizeColumns(rows); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	reportMarkdown(out, rows, rows[0].Count)
+	want := "| Grouping | Files | Blank | Comment | Code | Lines |\n" +
+		"| :--- | ---: | ---: | ---: | ---: | ---: |\n" +
+		"| a\\|b | 1 | 1 | 0 | 3 | 4 |\n" +
+		"| **Totals** | **1** | **1** | **0** | **3** | **4** |\n"
+	if out.String() != want {
+		t.Errorf("Markdown\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestReportDuplicates(t *testing.T) {
+	defer setEnv("COLUMNS", "80")()
+	if err := sizeColumns(nil); err != nil {
+		t.Fatal(err)
+	}
+	files := codecount.Files{
+		{Path: "src/copy.go", Duplicate: "src/main.go", Lines: 4, Blanks: 1, Code: 3},
+		{Path: "lib/copy.go", Duplicate: "src/main.go", Lines: 4, Blanks: 1, Code: 3},
+	}
+	out := captureStdout(t, func() { reportDuplicates(files) })
+	for _, want := range []string{
+		fmt.Sprintf("%-29s%10d%10d%10d%10d%10d\n", "src/copy.go", 1, 1, 0, 3, 4),
+		fmt.Sprintf("%-29s%10d%10d%10d%10d%10d\n", "Duplicates", 2, 2, 0, 6, 8),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Duplicates\n%s\nwant the line\n%s", out, want)
+		}
+	}
+	if out := captureStdout(t, func() { reportDuplicates(nil) }); out != "" {
+		t.Errorf("No duplicates printed %q, want nothing", out)
+	}
+}
+
+func TestReportGitHub(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	summary, output := filepath.Join(dir, "summary.md"), filepath.Join(dir, "output")
+	defer setEnv("GITHUB_STEP_SUMMARY", summary)()
+	defer setEnv("GITHUB_OUTPUT", output)()
+
+	result := &codecount.Result{Files: codecount.Files{
+		{Path: "main.go", Lang: codecount.Language{Name: "Go"}, Scanned: true, Lines: 4, Blanks: 1, Code: 3},
+	}}
+	outcomes := []codecount.Outcome{
+		{Suite: "max-code", Name: "code > 100"},
+		{Suite: "max-file-lines", Name: "a,b.go", Failure: "a,b.go has 4 lines, more than 2"},
+	}
+	out := captureStdout(t, func() { reportGitHub(result, outcomes) })
+	want := "::notice title=codecount::3 lines of code in 1 files\n" +
+		"::error title=codecount max-file-lines,file=a%2Cb.go::Limit failed: a,b.go has 4 lines, more than 2\n"
+	if out != want {
+		t.Errorf("Annotations %q, want %q", out, want)
+	}
+
+	data, err := ioutil.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Codecount\n", "| Go | 1 | 1 | 0 | 3 | 4 |\n",
+		"### Limits failed\n\n- a,b.go has 4 lines, more than 2\n"} {
+
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Job summary\n%s\nwant %q", data, want)
+		}
+	}
+
+	data, err = ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "files=1\ncode=3\ncomments=0\nblanks=1\nlines=4\nlanguages={\"Go\":3}\n"
+	if string(data) != want {
+		t.Errorf("Step outputs %q, want %q", data, want)
+	}
+}
+
+func TestEscapeGitHub(t *testing.T) {
+	if got := escapeData("50% done\nnext: a,b"); got != "50%25 done%0Anext: a,b" {
+		t.Errorf("escapeData gave %q", got)
+	}
+	if got := escapeProperty("C:\\a,b\r\n"); got != "C%3A\\a%2Cb%0D%0A" {
+		t.Errorf("escapeProperty gave %q", got)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := &logger{out: out, level: levelInfo}
+	if err := l.configure("trace", "text"); err == nil {
+		t.Error("Level trace was accepted")
+	}
+	if err := l.configure("info", "yaml"); err == nil {
+		t.Error("Format yaml was accepted")
+	}
+
+	if err := l.configure("INFO", "text"); err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("Hidden")
+	l.Info("Scanned", "path", "a b.go", "files", 2, "empty", "")
+	line := regexp.MustCompile(`^time=\S+ level=info msg=Scanned path="a b.go" files=2 empty=""\n$`)
+	if !line.MatchString(out.String()) {
+		t.Errorf("Text log %q", out.String())
+	}
+
+	out.Reset()
+	if err := l.configure("warn", "json"); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("Hidden")
+	l.Warn("Limit failed", "failure", `code > 1 "quoted"`)
+	record := map[string]string{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("JSON log %q: %v", out.String(), err)
+	}
+	if record["level"] != "warn" || record["msg"] != "Limit failed" || record["failure"] != `code > 1 "quoted"` {
+		t.Errorf("JSON log %v", record)
+	}
+}
+
+func TestDebugWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := &logger{out: out}
+	if err := l.configure("debug", "json"); err != nil {
+		t.Fatal(err)
+	}
+	var w io.Writer = debugWriter{l}
+	line := "CODE\tmain.go\tfunc main() {\t}\n"
+	if n, err := w.Write([]byte(line)); n != len(line) || err != nil {
+		t.Errorf("Write gave %d, %v", n, err)
+	}
+	w.Write([]byte("SKIP vendor\n"))
+
+	decoder := json.NewDecoder(out)
+	want := []map[string]string{
+		{"msg": "Classified", "class": "CODE", "file": "main.go", "line": "func main() {\t}"},
+		{"msg": "SKIP vendor"},
+	}
+	for _, fields := range want {
+		record := map[string]string{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record["level"] != "debug" {
+			t.Errorf("Record %v at level %s, want debug", record, record["level"])
+		}
+		for key, value := range fields {
+			if record[key] != value {
+				t.Errorf("Record %v has %s %q, want %q", record, key, record[key], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package main
+
+import (
+	"codecount"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// How often the progress is drawn again
+const progressInterval = 100 * time.Millisecond
+
+// Progress shows on a terminal the files and lines counted so far, the
+// lines a second and the directory being scanned, on one line drawn over
+type progress struct {
+	out   io.Writer
+	width int
+	start time.Time
+	drawn time.Time
+	files int
+	lines int
+	dir   string
+}
+
+func newProgress(out io.Writer, width int) *progress {
+	return &progress{out: out, width: width, start: time.Now()}
+}
+
+// Count the file, drawing the line again when it is due
+func (p *progress) add(file codecount.File) {
+	p.files++
+	p.lines += file.Lines
+	p.dir = filepath.Dir(file.Path)
+	if now := time.Now(); now.Sub(p.drawn) >= progressInterval {
+		p.drawn = now
+		p.draw(now)
+	}
+}
+
+func (p *progress) draw(now time.Time) {
+	rate := 0
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = int(float64(p.lines) / elapsed)
+	}
+	line := fmt.Sprintf("Scanned %s files, %s lines, %s lines/s  %s",
+		groupDigits(int64(p.files)), groupDigits(int64(p.lines)), groupDigits(int64(rate)), p.dir)
+	// Leave the last column free so the terminal does not wrap
+	if p.width > 1 && len(line) > p.width-1 {
+		line = line[:p.width-1]
+	}
+	fmt.Fprint(p.out, "\r"+line+"\x1b[K")
+}
+
+// Clear the line before the report is written
+func (p *progress) done() {
+	if !p.drawn.IsZero() {
+		fmt.Fprint(p.out, "\r\x1b[K")
+	}
+}
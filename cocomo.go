@@ -0,0 +1,45 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import "math"
+
+// Estimate is the basic COCOMO estimate of building the code, as an
+// organic project of a small team familiar with the work
+type Estimate struct {
+	Effort   float64 // Person-months
+	Schedule float64 // Months
+	People   float64 // Average people working
+	Cost     float64 // Effort at the monthly cost of a person
+}
+
+// Estimate the effort, schedule and cost of the lines of code, paying
+// rate for each person-month
+func EstimateCost(code int, rate float64) Estimate {
+	if code <= 0 {
+		return Estimate{}
+	}
+	effort := 2.4 * math.Pow(float64(code)/1000, 1.05)
+	schedule := 2.5 * math.Pow(effort, 0.38)
+	return Estimate{
+		Effort:   effort,
+		Schedule: schedule,
+		People:   effort / schedule,
+		Cost:     effort * rate,
+	}
+}
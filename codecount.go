@@ -17,493 +17,648 @@
 */
 // Count the lines in text type files recursively though a directory
 // based on the extension of the file.
-package main
+package codecount
 
 import (
-	"bufio"
 	"encoding/json"
-	"flag"
+	"encoding/xml"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
-	"runtime/pprof"
 	"sort"
 	"strings"
-	"time"
-)
-
-const VERSION = "0.3"
-
-var (
-	ROOT        = string(".")
-	ARG_JSON    = flag.Bool("json", false, "Output JSON")
-	ARG_VERSION = flag.Bool("v", false, "Display Version")
-	ARG_BYFILE  = flag.Bool("f", false, "Report by File")
-	ARG_BYPATH  = flag.Bool("p", false, "Report by Path")
-	ARG_DEBUG   = flag.Bool("d", false, "Enable Debug output")
-	ARG_INCLUDE = flag.Bool("i", false, "Include Duplicate Files")
-	ARG_OMIT    = flag.String("omit", "", "Omit Files by Regex Match")
-	ARG_PROFILE = flag.String("cpuprofile", "", "Write cpu profile to file")
-	ARG_MEMORY  = flag.String("memprofile", "", "Write mem profile to file")
 )
 
+// File is a single file found while walking and its line counts
 type File struct {
-	path     string      // Path of the file
-	info     os.FileInfo // Complete file info returned by ioutil
-	lang     Language    // Language
-	scanned  bool        // Was this scanned
-	lines    int         // Total Lines
-	comments int         // Comment Lines
-	blanks   int         // Blank Lintes
-	code     int         // Code Lines
+	Path       string      // Path of the file
+	Root       string      // Root the file was found scanning
+	Info       os.FileInfo // Complete file info returned by ioutil
+	Lang       Language    // Language
+	Scanned    bool        // Was this scanned
+	Listed     bool        // Found by an inventory, counted without reading
+	Binary     bool        // Skipped as the content is not text
+	Large      bool        // Skipped as larger than the maximum size
+	Invalid    bool        // Skipped as the content is not valid in its encoding
+	Encoding   string      // Encoding of the content when not UTF-8, as UTF-16LE
+	LineEnding string      // Most common line ending, LF, CRLF or CR
+	Generated  bool        // Written by a tool, kept out of the totals
+	Vendored   bool        // In a directory of dependencies, kept out of the totals
+	Lines      int         // Total Lines
+	Comments   int         // Comment Lines
+	Blanks     int         // Blank Lintes
+	Code       int         // Code Lines
+	Mixed      int         // Code Lines also holding a comment
+	Docs       int         // Comment Lines documenting the code
+	Complexity int         // Branches taken in the code, when measured
+	Functions  int         // Functions and methods defined, when counted
+	Longest    int         // Characters of the longest line
+	Chars      int         // Characters of all lines, without line endings
+
+	Embedded  map[string]*Count // Lines attributed to another bucket
+	Hash      string            // SHA-1 of the content
+	Duplicate string            // Path of the file with the same content
+
+	classified []classifiedLine              // Lines kept for diffing
+	open       func() (io.ReadCloser, error) // Opens content not on disk
+}
+
+// Open the content of the file
+func (file *File) reader() (io.ReadCloser, error) {
+	if file.open != nil {
+		return file.open()
+	}
+	return os.Open(file.Path)
+}
+
+// Read all of the content of the file as UTF-8 text
+func (file *File) read() ([]byte, error) {
+	f, err := file.text()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// Count is the line counts for a grouping of files
+type Count struct {
+	Files      int   `json:"files" xml:"files"`
+	Blanks     int   `json:"blanks" xml:"blanks"`
+	Comments   int   `json:"comments" xml:"comments"`
+	Code       int   `json:"code" xml:"code"`
+	Lines      int   `json:"lines" xml:"lines"`
+	Mixed      int   `json:"mixed,omitempty" xml:"mixed,omitempty"`
+	Docs       int   `json:"docs,omitempty" xml:"docs,omitempty"`
+	Complexity int   `json:"complexity,omitempty" xml:"complexity,omitempty"`
+	Functions  int   `json:"functions,omitempty" xml:"functions,omitempty"`
+	Longest    int   `json:"longest,omitempty" xml:"longest,omitempty"` // Characters of the longest line
+	Chars      int   `json:"chars,omitempty" xml:"chars,omitempty"`     // Characters of all lines
+	Bytes      int64 `json:"bytes" xml:"bytes"`                         // Size of the files
+}
+
+// Add the counts of another to this one
+func (c *Count) Add(o Count) {
+	c.Files += o.Files
+	c.Blanks += o.Blanks
+	c.Comments += o.Comments
+	c.Code += o.Code
+	c.Lines += o.Lines
+	c.Mixed += o.Mixed
+	c.Docs += o.Docs
+	c.Complexity += o.Complexity
+	c.Functions += o.Functions
+	c.Chars += o.Chars
+	c.Bytes += o.Bytes
+	if o.Longest > c.Longest {
+		c.Longest = o.Longest
+	}
+}
+
+// Subtract the line counts of another from this one
+func (c *Count) Sub(o Count) {
+	c.Blanks -= o.Blanks
+	c.Comments -= o.Comments
+	c.Code -= o.Code
+	c.Lines -= o.Lines
+	c.Mixed -= o.Mixed
+	c.Docs -= o.Docs
+	c.Complexity -= o.Complexity
+	c.Functions -= o.Functions
+	c.Chars -= o.Chars
+	c.Bytes -= o.Bytes
+}
+
+// The mean length of the lines in characters, rounded
+func (c Count) Average() int {
+	if c.Lines == 0 {
+		return 0
+	}
+	return (c.Chars + c.Lines/2) / c.Lines
+}
+
+// Group is a named row of totals in a report
+type Group struct {
+	Name string `json:"name" xml:"name,attr"`
+	Count
+}
+
+// The value of a column of the count by its name
+func (c Count) Column(name string) (int, bool) {
+	switch name {
+	case "files":
+		return c.Files, true
+	case "blanks":
+		return c.Blanks, true
+	case "comments":
+		return c.Comments, true
+	case "code":
+		return c.Code, true
+	case "lines":
+		return c.Lines, true
+	case "mixed":
+		return c.Mixed, true
+	case "docs":
+		return c.Docs, true
+	case "complexity":
+		return c.Complexity, true
+	case "functions":
+		return c.Functions, true
+	case "longest":
+		return c.Longest, true
+	case "average":
+		return c.Average(), true
+	case "bytes":
+		return int(c.Bytes), true
+	}
+	return 0, false
+}
+
+// Order the groups by a column, largest first or by name from A to Z,
+// reversed when asked
+func SortGroups(groups []Group, by string, reverse bool) error {
+	less := func(a, b Group) bool { return a.Name < b.Name }
+	if by != "name" {
+		if _, found := (Count{}).Column(by); !found {
+			return fmt.Errorf("cannot sort by %q", by)
+		}
+		less = func(a, b Group) bool {
+			x, _ := a.Column(by)
+			y, _ := b.Column(by)
+			return x > y
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if reverse {
+			return less(groups[j], groups[i])
+		}
+		return less(groups[i], groups[j])
+	})
+	return nil
 }
 
 type Files []File
 
 func (f Files) Len() int           { return len(f) }
 func (f Files) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
-func (f Files) Less(i, j int) bool { return f[i].lines > f[j].lines }
+func (f Files) Less(i, j int) bool { return f[i].Lines > f[j].Lines }
 
 type FileByLang struct{ Files }
 
 func (f FileByLang) Less(i, j int) bool {
-	return f.Files[i].lang.name < f.Files[j].lang.name
+	return f.Files[i].Lang.Name < f.Files[j].Lang.Name
 }
 
 type FileByPath struct{ Files }
 
 func (f FileByPath) Less(i, j int) bool {
-	return f.Files[i].path < f.Files[j].path
-}
-
-type Language struct {
-	name       string   // Print name
-	extension  []string // File Extensions
-	openblock  string   // Block comment opening
-	closeblock string   // Block comment closing
-	comment    string   // Line comment markers
-	endmark    string   // End of code marker
-}
-type Languages []Language
-
-var languages = Languages{
-	Language{"Assembly", []string{".s"}, "", "", ";", ""},
-	Language{"Batch", []string{".bat"}, "", "", "REM", ""},
-	Language{"C", []string{".c"}, "/*", "*/", "//", ""},
-	Language{"C++", []string{".cpp"}, "/*", "*/", "//", ""},
-	Language{"C/C++ Header", []string{".h"}, "/*", "*/", "//", ""},
-	Language{"CSS", []string{".css"}, "/*", "*/", "", ""},
-	Language{"C#", []string{".cs"}, "/*", "*/", "//", ""},
-	Language{"Go", []string{".go"}, "/*", "*/", "//", ""},
-	Language{"HTML", []string{".html", ".htm"}, "", "", "", ""},
-	Language{"Java", []string{".java"}, "/*", "*/", "//", ""},
-	Language{"Javascript", []string{".js"}, "/*", "*/", "//", ""},
-	Language{"JSON", []string{".json"}, "", "", "", ""},
-	Language{"Markdown", []string{".md"}, "", "", "", ""},
-	Language{"Perl", []string{".pl"}, "/*", "*/", "//", "__END__"},
-	Language{"PHP", []string{".php"}, "/*", "*/", "//", "__halt_compiler()"},
-	Language{"Python", []string{".py", ".pyw"}, "", "", "#", ""},
-	Language{"RestructuredText", []string{".rst"}, "", "", "", ""},
-	Language{"RPGLE", []string{".rpgle"}, "", "", "", ""},
-	Language{"Ruby", []string{".rb"}, "/*", "*/", "#", "__END__"},
-	Language{"Rust", []string{".rs"}, "/*", "*/", "//", ""},
-	Language{"SQL", []string{".sql"}, "/*", "*/", "", ""},
-	Language{"TCL", []string{".tcl"}, "", "", "#", ""},
-	Language{"Text", []string{".txt"}, "", "", "", ""},
-	Language{"VB", []string{".vb", ".mac", ".frm", ".frx", ".bas"}, "/*", "*/", "'", ""},
-	Language{"XML", []string{".xml", ".xss", ".xsc", ".xsd", ".xsx"}, "", "", "", ""},
-}
-
-// Setup the set of extension types to scan
-var extensions = func() map[string]Language {
-	ext_set := map[string]Language{}
-	for _, lang := range languages {
-		for _, ext := range lang.extension {
-			ext_set[ext] = lang
+	return f.Files[i].Path < f.Files[j].Path
+}
+
+// Result holds the files found by a scan
+type Result struct {
+	Files   Files
+	Links   int      // Links to directories skipped
+	Partial bool     // Scanning was stopped before every file was counted
+	Summary *Summary // The files folded in when streaming, nil otherwise
+}
+
+// Totals of all scanned files
+func (r *Result) Totals() Count {
+	total := Count{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Counted() {
+			total.Add(r.Files[i].count())
 		}
 	}
-	return ext_set
-}()
+	if r.Summary != nil {
+		total.Add(r.Summary.Totals)
+	}
+	return total
+}
 
-// States for scanning
-const (
-	NORMAL = iota
-	BLOCK
-	END
-)
+// Total the scanned files by language, lines attributed to an
+// embedded bucket are moved from the language to the bucket
+func (r *Result) ByLanguage() []Group {
+	names := []string{}
+	totals := map[string]*Count{}
+	total := func(name string) *Count {
+		if _, found := totals[name]; !found {
+			names = append(names, name)
+			totals[name] = &Count{}
+		}
+		return totals[name]
+	}
 
-var files = []File{}
-var omitFilter *regexp.Regexp
+	for i := 0; i < len(r.Files); i++ {
+		if !r.Files[i].Counted() {
+			continue
+		}
+		lang := total(r.Files[i].Lang.Name)
+		lang.Add(r.Files[i].count())
+		for name, count := range r.Files[i].Embedded {
+			lang.Sub(*count)
+			total(name).Add(*count)
+		}
+	}
+	if r.Summary != nil {
+		for name, count := range r.Summary.Languages {
+			total(name).Add(*count)
+		}
+	}
 
-// Run the codecounter
-func main() {
-	file_count := 0
-	blank_count := 0
-	comment_count := 0
-	code_count := 0
-	line_count := 0
-	start := time.Now()
-	flag.Parse()
-	args := flag.Args()
-	if len(args) == 1 {
-		ROOT = args[0]
+	sort.Strings(names)
+	groups := make([]Group, len(names))
+	for i, name := range names {
+		groups[i] = Group{name, *totals[name]}
 	}
+	return groups
+}
 
-	if *ARG_VERSION {
-		fmt.Printf("Codecount %s\n", VERSION)
-		return
+// Paths of the files added, removed or modified since the previous result
+func (r *Result) Changed(previous *Result) []string {
+	before := map[string]os.FileInfo{}
+	for _, file := range previous.Files {
+		before[file.Path] = file.Info
 	}
+	changed := []string{}
+	for _, file := range r.Files {
+		info, found := before[file.Path]
+		if !found || info == nil || file.Info == nil || info.Size() != file.Info.Size() ||
+			!info.ModTime().Equal(file.Info.ModTime()) {
 
-	if *ARG_PROFILE != "" {
-		f, err := os.Create(*ARG_PROFILE)
-		if err != nil {
-			log.Fatal(err)
+			changed = append(changed, file.Path)
 		}
-		pprof.StartCPUProfile(f)
-		defer pprof.StopCPUProfile()
+		delete(before, file.Path)
 	}
+	for path := range before {
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	return changed
+}
 
-	if *ARG_OMIT != "" {
-		var err error
-		omitFilter, err = regexp.Compile(*ARG_OMIT)
-		if err != nil {
-			log.Fatal("Omit regex failed to parse: " + err.Error())
+// Keep the largest top groups with at least minLines lines in their order,
+// totalling the rest into a last group; zero limits keep everything
+func TrimGroups(groups []Group, top, minLines int) []Group {
+	largest := make([]int, len(groups))
+	for i := range largest {
+		largest[i] = i
+	}
+	sort.SliceStable(largest, func(i, j int) bool {
+		return groups[largest[i]].Lines > groups[largest[j]].Lines
+	})
+	keep := make([]bool, len(groups))
+	for rank, i := range largest {
+		keep[i] = (top <= 0 || rank < top) && groups[i].Lines >= minLines
+	}
+
+	kept := []Group{}
+	hidden := Group{}
+	for i, group := range groups {
+		if keep[i] {
+			kept = append(kept, group)
+		} else {
+			hidden.Add(group.Count)
 		}
 	}
-	// Collect the files or single file
-	filepath.Walk(ROOT, walkFunc)
-
-	// Total scanned files
-	for i := 0; i < len(files); i++ {
-		if files[i].scanned {
-			file_count++
-			blank_count = blank_count + files[i].blanks
-			comment_count = comment_count + files[i].comments
-			code_count = code_count + files[i].code
-			line_count = line_count + files[i].lines
+	if len(kept) < len(groups) {
+		hidden.Name = fmt.Sprintf("(%d more)", len(groups)-len(kept))
+		kept = append(kept, hidden)
+	}
+	return kept
+}
+
+// Each counted file as its own group named by path, largest first
+func (r *Result) ByFile() []Group {
+	groups := []Group{}
+	sort.Sort(r.Files)
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Counted() {
+			groups = append(groups, Group{r.Files[i].Path, r.Files[i].count()})
 		}
 	}
+	return groups
+}
 
-	if *ARG_JSON {
-		json.NewEncoder(os.Stdout).Encode(files)
-	} else {
-		reportHeader()
-		reportDetail(files)
+// Total the files by the root they were found under, in the order scanned
+func (r *Result) ByRoot() []Group {
+	groups := []Group{}
+	index := map[string]int{}
+	for i := 0; i < len(r.Files); i++ {
+		if !r.Files[i].Counted() {
+			continue
+		}
+		root := r.Files[i].Root
+		if _, found := index[root]; !found {
+			index[root] = len(groups)
+			groups = append(groups, Group{Name: root})
+		}
+		groups[index[root]].Add(r.Files[i].count())
+	}
+	return groups
+}
 
-		end := time.Now()
-		fmt.Println(strings.Repeat("-", 79))
-		fmt.Printf("%-29s%10d%10d%10d%10d%10d\n",
-			"Totals",
-			file_count,
-			blank_count,
-			comment_count,
-			code_count,
-			line_count)
-		fmt.Println(strings.Repeat("-", 79))
-		fmt.Println("Runtime: ", end.Sub(start))
+// Total the scanned files sharing the same path
+func (r *Result) ByPath() []Group {
+	groups := []Group{}
+	sort.Sort(FileByPath{r.Files})
+	for i := 0; i < len(r.Files); i++ {
+		if !r.Files[i].Counted() {
+			continue
+		}
+		last := len(groups) - 1
+		if last < 0 || groups[last].Name != r.Files[i].Path {
+			groups = append(groups, Group{Name: r.Files[i].Path})
+			last++
+		}
+		groups[last].Add(r.Files[i].count())
 	}
+	return groups
+}
 
-	if *ARG_MEMORY != "" {
-		f, err := os.Create(*ARG_MEMORY)
+// Total the counted files by directory relative to the root, cut to
+// the depth given, like src/ or pkg/api/
+func (r *Result) ByDir(root string, depth int) []Group {
+	names := []string{}
+	totals := map[string]*Count{}
+	for i := 0; i < len(r.Files); i++ {
+		if !r.Files[i].Counted() {
+			continue
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(r.Files[i].Path))
 		if err != nil {
-			log.Fatal(err)
+			dir = filepath.Dir(r.Files[i].Path)
+		}
+		name := "./"
+		if dir != "." {
+			parts := strings.Split(filepath.ToSlash(dir), "/")
+			if len(parts) > depth {
+				parts = parts[:depth]
+			}
+			name = strings.Join(parts, "/") + "/"
 		}
-		pprof.WriteHeapProfile(f)
-		f.Close()
-		return
+		if _, found := totals[name]; !found {
+			names = append(names, name)
+			totals[name] = &Count{}
+		}
+		totals[name].Add(r.Files[i].count())
 	}
+
+	sort.Strings(names)
+	groups := make([]Group, len(names))
+	for i, name := range names {
+		groups[i] = Group{name, *totals[name]}
+	}
+	return groups
 }
 
-// Create the files
-func walkFunc(path string, info os.FileInfo, err error) error {
-	if omitFilter != nil {
-		if omitFilter.MatchString(path) {
-			return nil
-		}
+// The total of the counts of the files
+func (f Files) Totals() Count {
+	totals := Count{}
+	for i := range f {
+		totals.Add(f[i].count())
+	}
+	return totals
+}
+
+// The paths of the files
+func (f Files) Paths() []string {
+	paths := []string{}
+	for _, file := range f {
+		paths = append(paths, file.Path)
 	}
-	if info.IsDir() {
-		if path == "." {
-			return nil
+	return paths
+}
+
+// The files written by tools, scanned but kept out of the totals
+func (r *Result) Generated() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Scanned && r.Files[i].Generated && !r.Files[i].Vendored {
+			files = append(files, r.Files[i])
 		}
-		name := info.Name()
-		if strings.HasPrefix(name, ".") {
-			return filepath.SkipDir
-		} else if name == "__pycache__" {
-			return filepath.SkipDir
+	}
+	return files
+}
+
+// The files of dependencies, scanned or listed but kept out of the totals
+func (r *Result) Vendored() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if (r.Files[i].Scanned || r.Files[i].Listed) && r.Files[i].Vendored {
+			files = append(files, r.Files[i])
 		}
-	} else {
-		ext := filepath.Ext(path)
-		if _, found := extensions[ext]; found {
-			file := File{path: path, info: info}
-			file.scan()
-			files = append(files, file)
+	}
+	return files
+}
+
+// The files skipped as their content is not text
+func (r *Result) Binaries() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Binary {
+			files = append(files, r.Files[i])
 		}
 	}
-	return nil
+	return files
 }
 
-// Scans a single file, recording the stats
-func (file *File) scan() {
-	state := NORMAL
-	file.lang = extensions[strings.ToLower(filepath.Ext(file.path))]
+// The files counted that are empty or hold only whitespace
+func (r *Result) Empty() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Counted() && r.Files[i].empty() {
+			files = append(files, r.Files[i])
+		}
+	}
+	return files
+}
 
-	// Skip unknown files
-	if file.lang.name == "" || file.info.Size() == 0 {
-		file.scanned = false
-		return
+// The files skipped as their content is not valid in its encoding
+func (r *Result) Invalid() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Invalid {
+			files = append(files, r.Files[i])
+		}
 	}
+	return files
+}
 
-	// Open the file to begin scanning
-	f, err := os.Open(file.path)
-	if err != nil {
-		panic(err)
+// The files skipped as larger than the maximum size
+func (r *Result) Large() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Large {
+			files = append(files, r.Files[i])
+		}
 	}
-	defer f.Close()
+	return files
+}
 
-	// Read line by line of the file to classify
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			panic(err)
+// The files left out of the totals as duplicates of another
+func (r *Result) Duplicates() Files {
+	files := Files{}
+	for i := 0; i < len(r.Files); i++ {
+		if r.Files[i].Scanned && r.Files[i].Duplicate != "" {
+			files = append(files, r.Files[i])
 		}
-		line_orig := scanner.Text()
-		file.lines++
-
-		line := strings.TrimSpace(line_orig)
-		if line == "" {
-			file.blanks++
-			if *ARG_DEBUG {
-				fmt.Printf("BLNK\t%s\n", line_orig)
-			}
+	}
+	return files
+}
+
+// Mark each file with the same content as an earlier file
+func (r *Result) markDuplicates() {
+	seen := map[string]string{}
+	for i := 0; i < len(r.Files); i++ {
+		// Empty files are placeholders, alike without being copies
+		if !r.Files[i].Scanned || r.Files[i].empty() {
 			continue
 		}
+		if path, found := seen[r.Files[i].Hash]; found {
+			r.Files[i].Duplicate = path
+		} else {
+			seen[r.Files[i].Hash] = r.Files[i].Path
+		}
+	}
+}
 
-		/* In each line take the current state and decide
-		if conditions for another state have come up.
-		Start with the NORMAL state, if a block is opened
-		but not closed then the next line will resume in the
-		BLOCK state.  If the end marker has been encountered,
-		all further lines are in the END state.
-		*/
-		switch state {
-		case NORMAL:
-			if strings.HasPrefix(line, file.lang.comment) &&
-				file.lang.comment != "" {
-
-				file.comments++
-				if *ARG_DEBUG {
-					fmt.Printf("LCOM\t%s\n", line_orig)
-				}
-				continue
-			}
-
-			if strings.HasPrefix(line, file.lang.endmark) &&
-				file.lang.endmark != "" {
-
-				state = END
-				file.comments++
-				if *ARG_DEBUG {
-					fmt.Printf("ECOM\t%s\n", line_orig)
-				}
-				continue
-			}
+// Is the file included in the totals, scanned or listed and not a
+// duplicate
+func (file *File) Counted() bool {
+	return (file.Scanned || file.Listed) && file.Duplicate == "" && !file.Generated && !file.Vendored
+}
 
-			if file.lang.openblock != "" &&
-				file.lang.closeblock != "" {
-
-				spos := strings.LastIndex(line, file.lang.openblock)
-				epos := strings.LastIndex(line, file.lang.closeblock)
-
-				if spos > epos && spos > 1 {
-					state = BLOCK
-					file.code++
-					if *ARG_DEBUG {
-						fmt.Printf("COCM\t%s\n", line_orig)
-					}
-					continue
-				} else if spos > epos {
-					state = BLOCK
-					file.comments++
-					if *ARG_DEBUG {
-						fmt.Printf("OCOM\t%s\n", line_orig)
-					}
-					continue
-				} else if spos < epos && spos > -1 {
-					state = NORMAL
-					file.comments++
-					if *ARG_DEBUG {
-						fmt.Printf("BCOM\t%s\n", line_orig)
-					}
-					continue
-				}
-			}
+// Does the file hold nothing but blank lines, if any, as far as known
+func (file *File) empty() bool {
+	return !file.Listed && file.Lines == file.Blanks
+}
 
-			file.code++
-			if *ARG_DEBUG {
-				fmt.Printf("CODE\t%s\n", line_orig)
-			}
+// The counts of the file as a single file grouping
+func (file *File) count() Count {
+	size := int64(0)
+	if file.Info != nil {
+		size = file.Info.Size()
+	}
+	return Count{1, file.Blanks, file.Comments, file.Code, file.Lines, file.Mixed, file.Docs,
+		file.Complexity, file.Functions, file.Longest, file.Chars, size}
+}
 
-		case BLOCK:
-			spos := strings.LastIndex(line, file.lang.openblock)
-			epos := strings.LastIndex(line, file.lang.closeblock)
-
-			if spos < epos && epos != -1 {
-				state = NORMAL
-				if *ARG_DEBUG {
-					fmt.Printf("CCOM\t%s\n", line_orig)
-				}
-			} else {
-				if *ARG_DEBUG {
-					fmt.Printf("BCOM\t%s\n", line_orig)
-				}
-			}
-			file.comments++
+// Add the lines of a part of the file counted on its own
+func (file *File) merge(part *File) {
+	file.Lines += part.Lines
+	file.Blanks += part.Blanks
+	file.Comments += part.Comments
+	file.Code += part.Code
+	file.Mixed += part.Mixed
+	file.Docs += part.Docs
+	file.Complexity += part.Complexity
+	file.Functions += part.Functions
+	file.Chars += part.Chars
+	if part.Longest > file.Longest {
+		file.Longest = part.Longest
+	}
+	file.classified = append(file.classified, part.classified...)
+}
 
-		case END:
-			file.comments++
-			if *ARG_DEBUG {
-				fmt.Printf("ECOM\t%s\n", line_orig)
-			}
-		}
+// The counts of lines attributed to an embedded bucket
+func (file *File) embed(name string) *Count {
+	if file.Embedded == nil {
+		file.Embedded = map[string]*Count{}
+	}
+	if _, found := file.Embedded[name]; !found {
+		file.Embedded[name] = &Count{Files: 1}
+	}
+	return file.Embedded[name]
+}
 
+// Read a file back from a JSON report, without its content
+func (file *File) UnmarshalJSON(data []byte) error {
+	f := fileRecord{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	lang := languageNamed(f.Language)
+	if lang.Name == "" {
+		lang.Name = f.Language
 	}
-	file.scanned = true
+	*file = File{
+		Path:       f.Path,
+		Info:       contentInfo{f.Name, f.Bytes},
+		Lang:       lang,
+		Scanned:    f.Language != "",
+		Lines:      f.Lines,
+		Comments:   f.Comments,
+		Blanks:     f.Blanks,
+		Code:       f.Code,
+		Mixed:      f.Mixed,
+		Docs:       f.Docs,
+		Complexity: f.Complexity,
+		Functions:  f.Functions,
+		Longest:    f.Longest,
+		Chars:      f.Chars,
+		Encoding:   f.Encoding,
+		LineEnding: f.LineEnding,
+		Hash:       f.Hash,
+		Duplicate:  f.Duplicate,
+	}
+	return nil
 }
 
 func (file File) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Name     string `json:"name"`
-		Path     string `json:"path"`
-		Code     int    `json:"code"`
-		Blanks   int    `json:"blanks"`
-		Comments int    `json:"comments"`
-		Lines    int    `json:"lines"`
-		Language string `json:"language"`
-	}{
-		Name:     file.info.Name(),
-		Path:     file.path,
-		Code:     file.code,
-		Lines:    file.lines,
-		Blanks:   file.blanks,
-		Comments: file.comments,
-		Language: file.lang.name,
-	})
+	return json.Marshal(file.record())
 }
 
-// Print the report
-func reportDetail(files Files) {
-	if *ARG_BYFILE {
-		sort.Sort(files)
-		for i := 0; i < len(files); i++ {
-			if !files[i].scanned {
-				continue
-			}
-			name := files[i].info.Name()
-			if len(name) > 29 {
-				name = name[0:27] + ".."
-			}
-			fmt.Printf("%-29s%10d%10d%10d%10d%10d\n",
-				name,
-				1,
-				files[i].blanks,
-				files[i].comments,
-				files[i].code,
-				files[i].lines)
-		}
-	} else if *ARG_BYPATH {
-		path := ""
-		count, blanks, comments, code, lines := 0, 0, 0, 0, 0
-		sort.Sort(FileByPath{files})
-		for i := 0; i < len(files); i++ {
-			if !files[i].scanned {
-				continue
-			}
-			if path == "" {
-				path = files[i].path
-			}
-			if path != files[i].path {
-				if len(path) > 29 {
-					path = path[0:10] + "..." + path[len(path)-16:]
-				}
-				fmt.Printf("%-29s%10d%10d%10d%10d%10d\n",
-					path,
-					count,
-					blanks,
-					comments,
-					code,
-					lines)
-				path = files[i].path
-				count = 1
-				blanks = files[i].blanks
-				comments = files[i].comments
-				code = files[i].code
-				lines = files[i].lines
-			} else {
-				count++
-				blanks = files[i].blanks + blanks
-				comments = files[i].comments + comments
-				code = files[i].code + code
-				lines = files[i].lines + lines
-			}
+// Encode the file as a file element of the XML report
+func (file File) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "file"
+	return e.EncodeElement(file.record(), start)
+}
 
-		}
-		fmt.Printf("%-29s%10d%10d%10d%10d%10d\n",
-			path,
-			count,
-			blanks,
-			comments,
-			code,
-			lines)
-	} else {
-		lang := ""
-		count, blanks, comments, code, lines := 0, 0, 0, 0, 0
-		sort.Sort(FileByLang{files})
-		for i := 0; i < len(files); i++ {
-			if !files[i].scanned {
-				continue
-			}
-			if lang == "" {
-				lang = files[i].lang.name
-			}
-			if lang != files[i].lang.name {
-				fmt.Printf("%-29s%10d%10d%10d%10d%10d\n",
-					lang,
-					count,
-					blanks,
-					comments,
-					code,
-					lines)
-				lang = files[i].lang.name
-				count = 1
-				blanks = files[i].blanks
-				comments = files[i].comments
-				code = files[i].code
-				lines = files[i].lines
-			} else {
-				count++
-				blanks = files[i].blanks + blanks
-				comments = files[i].comments + comments
-				code = files[i].code + code
-				lines = files[i].lines + lines
-			}
+// The fields of the file given in reports
+type fileRecord struct {
+	Name       string `json:"name" xml:"name,attr"`
+	Path       string `json:"path" xml:"path"`
+	Code       int    `json:"code" xml:"code"`
+	Blanks     int    `json:"blanks" xml:"blanks"`
+	Comments   int    `json:"comments" xml:"comments"`
+	Lines      int    `json:"lines" xml:"lines"`
+	Mixed      int    `json:"mixed,omitempty" xml:"mixed,omitempty"`
+	Docs       int    `json:"docs,omitempty" xml:"docs,omitempty"`
+	Complexity int    `json:"complexity,omitempty" xml:"complexity,omitempty"`
+	Functions  int    `json:"functions,omitempty" xml:"functions,omitempty"`
+	Longest    int    `json:"longest,omitempty" xml:"longest,omitempty"`
+	Chars      int    `json:"chars,omitempty" xml:"chars,omitempty"`
+	Bytes      int64  `json:"bytes" xml:"bytes"`
+	Language   string `json:"language" xml:"language"`
+	Encoding   string `json:"encoding,omitempty" xml:"encoding,omitempty"`
+	LineEnding string `json:"lineEnding,omitempty" xml:"lineEnding,omitempty"`
+	Hash       string `json:"hash,omitempty" xml:"hash,omitempty"`
+	Duplicate  string `json:"duplicate,omitempty" xml:"duplicate,omitempty"`
+}
 
-		}
-		fmt.Printf("%-29s%10d%10d%10d%10d%10d\n",
-			lang,
-			count,
-			blanks,
-			comments,
-			code,
-			lines)
-	}
-}
-
-func reportHeader() {
-	fmt.Printf("Codecount - v %s\n", VERSION)
-	fmt.Println(strings.Repeat("-", 79))
-	fmt.Printf("%-29s%10s%10s%10s%10s%10s\n",
-		"Grouping", "Files", "Blank", "Comment", "Code", "Lines")
-	fmt.Println(strings.Repeat("-", 79))
+func (file File) record() fileRecord {
+	return fileRecord{
+		Name:       file.Info.Name(),
+		Path:       file.Path,
+		Code:       file.Code,
+		Lines:      file.Lines,
+		Blanks:     file.Blanks,
+		Comments:   file.Comments,
+		Mixed:      file.Mixed,
+		Docs:       file.Docs,
+		Complexity: file.Complexity,
+		Functions:  file.Functions,
+		Longest:    file.Longest,
+		Chars:      file.Chars,
+		Bytes:      file.count().Bytes,
+		Language:   file.Lang.Name,
+		Encoding:   file.Encoding,
+		LineEnding: file.LineEnding,
+		Hash:       file.Hash,
+		Duplicate:  file.Duplicate,
+	}
 }
@@ -1,9 +1,25 @@
-package main
+package codecount
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 var path = "test_files"
@@ -11,55 +27,2105 @@ var path = "test_files"
 // Test the javascript file
 func TestScanJS(t *testing.T) {
 	filename := path + string(os.PathSeparator) + "javascript.js"
-	test := File{path: filename, code: 16, lines: 27, comments: 9, blanks: 2}
+	test := File{Path: filename, Code: 16, Lines: 27, Comments: 9, Blanks: 2}
 	check_scan(t, filename, test)
 }
 
 // Test the PHP file
 func TestScanPHP(t *testing.T) {
 	filename := path + string(os.PathSeparator) + "php.php"
-	test := File{path: filename, code: 19, lines: 27, comments: 5, blanks: 3}
+	test := File{Path: filename, Code: 18, Lines: 27, Comments: 6, Blanks: 3}
 	check_scan(t, filename, test)
 }
 
+// Test the Puppet manifest
+func TestScanPuppet(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "site.pp"
+	test := File{Path: filename, Code: 8, Lines: 13, Comments: 4, Blanks: 1}
+	if file := check_scan(t, filename, test); file.Lang.Name != "Puppet" {
+		t.Errorf("Language is %s", file.Lang.Name)
+	}
+}
+
+// Test YAML is Ansible for the keys of a play at the top level only
+func TestScanAnsible(t *testing.T) {
+	for _, test := range []struct{ name, content, lang string }{
+		{"site.yml", "---\n- hosts: all\n  tasks:\n    - ping:\n", "Ansible"},
+		{"site.yml", "- name: Web servers\n  hosts: web\n", "Ansible"},
+		{"roles/web/tasks/main.yml", "- name: Ping\n  ping:\n", "Ansible"},
+		{"ingress.yaml", "kind: Ingress\nspec:\n  tls:\n  - hosts:\n    - example.com\n", "YAML"},
+		{"service.yaml", "kind: VirtualService\nspec:\n  hosts:\n  - reviews\n", "YAML"},
+		{"list.yaml", "- name: a\n  vars:\n    hosts: b\n", "YAML"},
+	} {
+		result, err := (&Scanner{}).ScanReader(strings.NewReader(test.content), test.name, languageNamed("YAML"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lang := result.Files[0].Lang.Name; lang != test.lang {
+			t.Errorf("%s is %s, not %s", test.name, lang, test.lang)
+		}
+	}
+}
+
+// Test each language from a file of its own, found by extension, file
+// name or content, with the lines that tell its comments from its code
+func TestScanLanguages(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		lang  string
+		want  File
+		lines map[string]string // The kind of lines, without their indent
+	}{
+		{"visualforce.page", "Visualforce", File{Code: 7, Lines: 13, Comments: 5, Blanks: 1}, map[string]string{
+			"Account summary page": "comment",
+			"<!-- Header -->":      "comment",
+		}},
+		{"page.html", "HTML", File{Code: 8, Lines: 16, Comments: 7, Blanks: 1}, map[string]string{
+			`<nav><a href="/">Home</a></nav> <!-- the menu`: "code",
+			"continues past the tag":                        "comment",
+			"<!DOCTYPE html>":                               "code",
+		}},
+		{"abap.abap", "ABAP", File{Code: 7, Lines: 12, Comments: 3, Blanks: 2}, map[string]string{
+			"* Report listing open orders":                       "comment",
+			"* Indented star is not a comment":                   "code",
+			`" Print each order`:                                 "comment",
+			`DATA lt_orders TYPE TABLE OF zorder. " Open orders`: "code",
+		}},
+		{"stata.do", "Stata", File{Code: 4, Lines: 11, Comments: 6, Blanks: 1}, map[string]string{
+			"* Summarize the auto dataset":      "comment",
+			"* indented star comment":           "comment",
+			"foreign origin */":                 "comment",
+			"// regress price on weight":        "comment",
+			"tabulate foreign // trailing note": "code",
+		}},
+		{"smalltalk.st", "Smalltalk", File{Code: 7, Lines: 13, Comments: 5, Blanks: 1}, map[string]string{
+			`keeps a running total"`:         "comment",
+			`count := count + 1. "trailing"`: "code",
+			`^ count "and a`:                 "code",
+			`comment that keeps going"`:      "comment",
+		}},
+		{"scheme.scm", "Scheme", File{Code: 5, Lines: 13, Comments: 7, Blanks: 1}, map[string]string{
+			"#| nested |#":                   "comment",
+			"still comment |#":               "comment",
+			`#;(display "disabled")`:         "comment",
+			"(display (fact 5)) #| trailing": "code",
+			"|#":                             "comment",
+		}},
+		{"coffeescript.coffee", "CoffeeScript", File{Code: 3, Lines: 10, Comments: 6, Blanks: 1}, map[string]string{
+			"Block comment with # inside": "comment",
+			"### Single line block ###":   "comment",
+			`"Hello #{name}"  # trailing`: "code",
+		}},
+		{"lua.lua", "Lua", File{Code: 5, Lines: 16, Comments: 9, Blanks: 2}, map[string]string{
+			`local s = "--[[ not a comment"`:  "code",
+			"--[==[ a comment holding ]] and": "comment",
+			"still going ]==]":                "comment",
+			"---[[ a line comment":            "comment",
+			"local M = {}":                    "code",
+		}},
+		{"install.sh", "Shell", File{Code: 9, Lines: 15, Comments: 4, Blanks: 2}, map[string]string{
+			"# not a comment, part of the file": "code",
+			"# still part of the document":      "code",
+			`grep -c x <<< "# a here string"`:   "code",
+			`echo "a << b"`:                     "code",
+			"# done":                            "comment",
+		}},
+		{"powershell.ps1", "PowerShell", File{Code: 4, Lines: 13, Comments: 8, Blanks: 1}, map[string]string{
+			".SYNOPSIS":                   "comment",
+			`Write-Host "Removed #$Path"`: "code",
+			"<# single line block #>":     "comment",
+		}},
+		{"haskell.hs", "Haskell", File{Code: 3, Lines: 9, Comments: 5, Blanks: 1}, map[string]string{
+			"{- with a nested comment -}":        "comment",
+			"still in the header -}":             "comment",
+			`main = putStrLn "{- not a comment"`: "code",
+		}},
+		{"literate.lhs", "Literate Haskell", File{Code: 4, Lines: 14, Comments: 6, Blanks: 4}, map[string]string{
+			"This module says hello, only the marked lines are source.": "comment",
+			"> -- a comment in the source":                              "comment",
+			"> main = greet":                                            "code",
+			`\begin{code}`:                                              "comment",
+			`greet = putStrLn "hello"`:                                  "code",
+		}},
+		{"notebook.ipynb", "Jupyter Notebook", File{Code: 3, Lines: 8, Comments: 3, Blanks: 2}, map[string]string{
+			"Load the data and plot it.": "comment",
+			"# load the data":            "comment",
+			"import csv":                 "code",
+		}},
+		{"settings.ini", "INI", File{Code: 5, Lines: 9, Comments: 3, Blanks: 1}, map[string]string{
+			"# Logging":                       "comment",
+			"port = 8080   ; the listen port": "code",
+		}},
+		{"config.toml", "TOML", File{Code: 5, Lines: 9, Comments: 3, Blanks: 1}, map[string]string{
+			`homepage = "https://example.com/#readme"`: "code",
+			"# skipped by default":                     "comment",
+			"skip = ['vendor', '#tmp']":                "code",
+		}},
+		{"main.tf", "Terraform", File{Code: 6, Lines: 11, Comments: 5, Blanks: 0}, map[string]string{
+			"# The bucket":                      "comment",
+			"* Storage for the build artifacts": "comment",
+			"# part of the policy document":     "code",
+		}},
+		{"kotlin.kt", "Kotlin", File{Code: 5, Lines: 12, Comments: 5, Blanks: 2}, map[string]string{
+			"/* nested inside the header */":                "comment",
+			"still the header */":                           "comment",
+			"// not a comment":                              "code",
+			`fun greet(name: String) = "Hello /* $name */"`: "code",
+		}},
+		{"julia.jl", "Julia", File{Code: 4, Lines: 10, Comments: 5, Blanks: 1}, map[string]string{
+			"#= nested =#":    "comment",
+			"=#":              "comment",
+			"# not a comment": "code",
+		}},
+		{"matlab.m", "MATLAB", File{Code: 3, Lines: 8, Comments: 5, Blanks: 0}, map[string]string{
+			"Plot the signal":    "comment",
+			"%}":                 "comment",
+			"plot(x);  % inline": "code",
+		}},
+		{"objc.m", "Objective-C", File{Code: 6, Lines: 9, Comments: 2, Blanks: 1}, map[string]string{
+			"#import <Foundation/Foundation.h>": "code",
+		}},
+		{"bridge.mm", "Objective-C++", File{Code: 5, Lines: 8, Comments: 2, Blanks: 1}, map[string]string{
+			"#include <string>": "code",
+		}},
+		{"header.h", "C++", File{Code: 6, Lines: 12, Comments: 3, Blanks: 3}, nil},
+		{"family.pl", "Prolog", File{Code: 4, Lines: 9, Comments: 3, Blanks: 2}, map[string]string{
+			"% Family relations": "comment",
+			"/* Facts */":        "comment",
+		}},
+		{"cobol.cbl", "COBOL", File{Code: 5, Lines: 10, Comments: 4, Blanks: 1}, map[string]string{
+			"* Payroll report":           "comment",
+			"/ new page":                 "comment",
+			"*> free form comment":       "comment",
+			`DISPLAY "*NOT A COMMENT*".`: "code",
+		}},
+		{"fortran.f", "Fortran 77", File{Code: 7, Lines: 10, Comments: 3, Blanks: 0}, map[string]string{
+			"C     Sum the first ten integers": "comment",
+			"*     running total":              "comment",
+			"PRINT *, N":                       "code",
+			"10 N = N + I":                     "code",
+		}},
+		{"fixed.rpgle", "RPGLE", File{Code: 7, Lines: 11, Comments: 3, Blanks: 1}, map[string]string{
+			"* Print the customer name": "comment",
+			"// look up the customer":   "comment",
+			"name = '*ACME*';":          "code",
+		}},
+		{"free.rpgle", "RPGLE", File{Code: 3, Lines: 6, Comments: 2, Blanks: 1}, map[string]string{
+			"**FREE":       "code",
+			"*inlr = *on;": "code",
+		}},
+		{"mysql.sql", "MySQL", File{Code: 6, Lines: 10, Comments: 3, Blanks: 1}, map[string]string{
+			"# Accounts schema":                    "comment",
+			"-- seed data":                         "comment",
+			"name VARCHAR(64) DEFAULT '#unnamed',": "code",
+		}},
+		{"assembly.s", "Assembly", File{Code: 4, Lines: 9, Comments: 5, Blanks: 0}, map[string]string{
+			"; load the status": "comment",
+			"@ arm style note":  "comment",
+			"// C++ style note": "comment",
+			"mov $0, %rdi":      "code",
+		}},
+		{"preprocessed.S", "Preprocessed Assembly", File{Code: 6, Lines: 8, Comments: 2, Blanks: 0}, map[string]string{
+			"#include <asm/unistd.h>":       "code",
+			"mov $0, %rdi        // status": "code",
+		}},
+		{"schema.graphql", "GraphQL", File{Code: 5, Lines: 11, Comments: 6, Blanks: 0}, map[string]string{
+			"priced in cents":               "comment",
+			`"The display name"`:            "code",
+			"name: String # shown in lists": "code",
+		}},
+		{"nim.nim", "Nim", File{Code: 3, Lines: 9, Comments: 5, Blanks: 1}, map[string]string{
+			"#[ nested block ]#":                               "comment",
+			"still a comment ]#":                               "comment",
+			"## Greet someone by name":                         "comment",
+			`echo "Hello #[ not a comment ", name  # trailing`: "code",
+		}},
+		{"elm.elm", "Elm", File{Code: 4, Lines: 11, Comments: 5, Blanks: 2}, nil},
+		{"purescript.purs", "PureScript", File{Code: 4, Lines: 9, Comments: 4, Blanks: 1}, nil},
+		{"reason.re", "Reason", File{Code: 2, Lines: 7, Comments: 4, Blanks: 1}, nil},
+		{"rescript.res", "ReScript", File{Code: 2, Lines: 7, Comments: 4, Blanks: 1}, nil},
+		{"gherkin.feature", "Gherkin", File{Code: 5, Lines: 9, Comments: 3, Blanks: 1}, nil},
+		{"nix.nix", "Nix", File{Code: 5, Lines: 10, Comments: 4, Blanks: 1}, nil},
+		{"BUILD", "Starlark", File{Code: 5, Lines: 8, Comments: 2, Blanks: 1}, nil},
+		{"Makefile", "Makefile", File{Code: 4, Lines: 8, Comments: 3, Blanks: 1}, nil},
+		{"Dockerfile", "Dockerfile", File{Code: 3, Lines: 7, Comments: 3, Blanks: 1}, nil},
+		{"Jenkinsfile", "Groovy", File{Code: 6, Lines: 9, Comments: 3, Blanks: 0}, nil},
+		{"Rakefile", "Ruby", File{Code: 4, Lines: 8, Comments: 3, Blanks: 1}, nil},
+		{"CMakeLists.txt", "CMake", File{Code: 3, Lines: 8, Comments: 4, Blanks: 1}, nil},
+	} {
+		filename := path + string(os.PathSeparator) + test.name
+		test.want.Path = filename
+		if file := check_scan(t, filename, test.want); file.Lang.Name != test.lang {
+			t.Errorf("%s is %s, not %s", test.name, file.Lang.Name, test.lang)
+		}
+		kinds := lineKinds(t, filename)
+		for line, want := range test.lines {
+			if kinds[line] != want {
+				t.Errorf("%s: %q is %s, not %s", test.name, line, kinds[line], want)
+			}
+		}
+	}
+
+	// File names are matched in any directory, whatever the extension
+	for name, lang := range map[string]string{
+		"src/BUILD.bazel": "Starlark",
+		"WORKSPACE":       "Starlark",
+		"lib/GNUmakefile": "Makefile",
+		"Gemfile":         "Ruby",
+		"app/Dockerfile":  "Dockerfile",
+	} {
+		if found, ok := Detect(name); !ok || found.Name != lang {
+			t.Errorf("%s detected as %s, not %s", name, found.Name, lang)
+		}
+	}
+}
+
+// Test the Ruby file where << is an operator as well as a here document
+func TestScanHereDocOperator(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "heredoc.rb"
+	test := File{Path: filename, Code: 11, Lines: 14, Comments: 3, Blanks: 0}
+	check_scan(t, filename, test)
+
+	ruby := languageNamed("Ruby")
+	for line, want := range map[string]string{
+		"class << self":      "",
+		"arr << x":           "",
+		"items << <<~TEXT":   "TEXT",
+		`sql = <<-"SQL"`:     "SQL",
+		`puts "a <<EOF"`:     "",
+		"x = 1 # y << <<END": "",
+	} {
+		if got := ruby.hereDoc(line); got != want {
+			t.Errorf("Here document of %q is %q", line, got)
+		}
+	}
+}
+
+// Test the SQL file
+func TestScanSQL(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "sql.sql"
+	test := File{Path: filename, Code: 5, Lines: 10, Comments: 4, Blanks: 1}
+	check_scan(t, filename, test)
+}
+
+// Test the Rust file with nested block comments
+func TestScanRust(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "rust.rs"
+	test := File{Path: filename, Code: 4, Lines: 11, Comments: 6, Blanks: 1}
+	check_scan(t, filename, test)
+}
+
+// Test the D file with both kinds of block comments
+func TestScanD(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "d.d"
+	test := File{Path: filename, Code: 4, Lines: 10, Comments: 5, Blanks: 1}
+	check_scan(t, filename, test)
+}
+
+// Test the extensionless script detected by its #! line
+func TestScanShebang(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "deploy"
+	test := File{Path: filename, Code: 2, Lines: 6, Comments: 3, Blanks: 1}
+	file := check_scan(t, filename, test)
+
+	if file.Lang.Name != "Python" {
+		t.Error("Language wrong")
+	}
+	for line, name := range map[string]string{
+		"#!/bin/sh":                "Shell",
+		"#!/usr/bin/env -S node":   "Javascript",
+		"#!/usr/local/bin/perl -w": "Perl",
+		"#!/usr/bin/python3.11":    "Python",
+	} {
+		if lang, _ := detectShebang(line); lang.Name != name {
+			t.Errorf("Shebang %s wrong", line)
+		}
+	}
+}
+
+// Test comments inside the markup of a TSX component
+func TestScanTSX(t *testing.T) {
+	scanner := &Scanner{CountPartial: true}
+
+	filename := path + string(os.PathSeparator) + "component.tsx"
+	test := File{Path: filename, Code: 9, Lines: 16, Comments: 6, Blanks: 1}
+	file := check_scan_with(t, scanner, filename, test)
+
+	if file.Mixed != 0 {
+		t.Error("Mixed wrong")
+	}
+}
+
+// Test the sections of a Vue component counted in their languages
+func TestScanSections(t *testing.T) {
+	scanner := &Scanner{SplitEmbedded: true}
+
+	filename := path + string(os.PathSeparator) + "component.vue"
+	test := File{Path: filename, Code: 16, Lines: 23, Comments: 5, Blanks: 2}
+	file := check_scan_with(t, scanner, filename, test)
+
+	for name, code := range map[string]int{"HTML": 6, "TypeScript": 3, "CSS": 1} {
+		if embed := file.Embedded[name]; embed == nil || embed.Code != code {
+			t.Errorf("Section %s wrong", name)
+		}
+	}
+}
+
+// Test the scripts and styles of an HTML page
+func TestScanSplitEmbedded(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "embedded.html"
+	test := File{Path: filename, Code: 12, Lines: 16, Comments: 4, Blanks: 0}
+	file := check_scan_with(t, &Scanner{SplitEmbedded: true}, filename, test)
+
+	for name, code := range map[string]int{"Javascript": 1, "CSS": 1} {
+		if embed := file.Embedded[name]; embed == nil || embed.Code != code {
+			t.Errorf("Section %s wrong", name)
+		}
+	}
+
+	test = File{Path: filename, Code: 13, Lines: 16, Comments: 3, Blanks: 0}
+	check_scan(t, filename, test)
+}
+
+// Test comment markers inside string literals
+func TestScanStrings(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "strings.c"
+	test := File{Path: filename, Code: 8, Lines: 12, Comments: 3, Blanks: 1}
+	check_scan(t, filename, test)
+
+	golang := languageNamed("Go")
+	for line, want := range map[string]string{
+		"s := \"http://example.com\"":       "s := \"\"",
+		"s := `/* raw \\` + \"/*\" // c":    "s := `` + \"\" // c",
+		"s := '\\'' /* \"c\" */ + \"\\\"\"": "s := '' /* \"c\" */ + \"\"",
+	} {
+		if code, _ := golang.stripStrings(line); code != want {
+			t.Errorf("Strings %s wrong: %s", line, code)
+		}
+	}
+	if _, raw := golang.stripStrings("s := `multi"); raw != "`" {
+		t.Error("Raw string left open wrong")
+	}
+}
+
+// Test the PHP file with comment classification disabled
+func TestScanNoComments(t *testing.T) {
+	scanner := &Scanner{NoComments: map[string]bool{"json": true, "php": true}}
+
+	filename := path + string(os.PathSeparator) + "php.php"
+	test := File{Path: filename, Code: 24, Lines: 27, Comments: 0, Blanks: 3}
+	check_scan_with(t, scanner, filename, test)
+}
+
+// Test SQL embedded in a Python string
+func TestScanEmbeddedSQL(t *testing.T) {
+	scanner := &Scanner{EmbeddedSQL: true}
+
+	filename := path + string(os.PathSeparator) + "embedded_sql.py"
+	test := File{Path: filename, Code: 14, Lines: 24, Comments: 4, Blanks: 6}
+	file := check_scan_with(t, scanner, filename, test)
+
+	// The string passed to execute is SQL, not a docstring
+	if sql := file.Embedded["Embedded SQL"]; sql == nil || sql.Code != 5 {
+		t.Error("Embedded SQL wrong")
+	}
+}
+
+// Test Python docstrings counted as code
+func TestScanDocStringsAsCode(t *testing.T) {
+	scanner := &Scanner{DocStringsAsCode: true}
+
+	filename := path + string(os.PathSeparator) + "embedded_sql.py"
+	test := File{Path: filename, Code: 16, Lines: 24, Comments: 2, Blanks: 6}
+	check_scan_with(t, scanner, filename, test)
+}
+
+// Test lines holding both code and comments
+func TestScanPartial(t *testing.T) {
+	scanner := &Scanner{CountPartial: true}
+
+	filename := path + string(os.PathSeparator) + "partial.c"
+	test := File{Path: filename, Code: 9, Lines: 13, Comments: 3, Blanks: 1}
+	file := check_scan_with(t, scanner, filename, test)
+
+	if file.Mixed != 5 {
+		t.Error("Mixed wrong")
+	}
+}
+
+// Test the Markdown file with front matter
+func TestScanFrontMatter(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "frontmatter.md"
+	test := File{Path: filename, Code: 5, Lines: 12, Comments: 4, Blanks: 3}
+	file := check_scan(t, filename, test)
+
+	if front := file.Embedded["Front Matter"]; front == nil ||
+		front.Code != 5 || front.Lines != 7 {
+		t.Error("Front Matter wrong")
+	}
+
+	// No fence closes it, so it is read as the rest of the file
+	content := "---\ntitle: Open\n\n# Heading\nProse.\n"
+	result, err := (&Scanner{}).ScanReader(strings.NewReader(content), "open.md", languageNamed("Markdown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := result.Files[0]
+	if open.Lines != 5 || open.Blanks != 1 || open.Comments != 4 || open.Embedded["Front Matter"] != nil {
+		t.Errorf("Unclosed front matter wrong: %+v", open.count())
+	}
+}
+
+// Test the Markdown file with fenced code blocks
+func TestScanFences(t *testing.T) {
+	filename := path + string(os.PathSeparator) + "fences.md"
+	test := File{Path: filename, Code: 10, Lines: 18, Comments: 3, Blanks: 5}
+	file := check_scan(t, filename, test)
+
+	if sh := file.Embedded["Shell"]; sh == nil || sh.Code != 2 || sh.Lines != 3 {
+		t.Error("Shell fence wrong")
+	}
+	if golang := file.Embedded["Go"]; golang == nil || golang.Code != 1 {
+		t.Error("Go fence wrong")
+	}
+}
+
+// Test matching of .gitignore patterns
+func TestIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		dir     bool
+		ignored bool
+	}{
+		{"node_modules/", "node_modules", true, true},
+		{"node_modules/", "web/node_modules", true, true},
+		{"node_modules/", "node_modules", false, false},
+		{"*.min.js", "static/app.min.js", false, true},
+		{"/dist", "dist", true, true},
+		{"/dist", "web/dist", true, false},
+		{"docs/*.md", "docs/index.md", false, true},
+		{"docs/*.md", "docs/api/index.md", false, false},
+		{"**/build", "a/b/build", true, true},
+		{"logs/**", "logs/a/b.log", false, true},
+		{"a/**/z", "a/b/c/z", false, true},
+		{"file[0-9].txt", "file7.txt", false, true},
+	}
+	for _, test := range tests {
+		rule, _ := parseIgnore(test.pattern)
+		stack := ignoreStack{&ignoreFile{dir: ".", rules: []ignoreRule{rule}}}
+		if stack.ignored(filepath.FromSlash(test.path), test.dir) != test.ignored {
+			t.Errorf("Pattern %s on %s wrong", test.pattern, test.path)
+		}
+	}
+
+	exclude, _ := parseIgnore("*.log")
+	include, _ := parseIgnore("!keep.log")
+	stack := ignoreStack{&ignoreFile{dir: ".", rules: []ignoreRule{exclude, include}}}
+	if stack.ignored("keep.log", false) || !stack.ignored("other.log", false) {
+		t.Error("Negated pattern wrong")
+	}
+}
+
+// Test include and exclude globs while walking
+func TestScanGlobs(t *testing.T) {
+	scanner := &Scanner{Include: []string{"*.php", "*.js"}, Exclude: []string{"*.js"}}
+	result, err := scanner.Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Lang.Name != "PHP" {
+		t.Error("Globs wrong")
+	}
+}
+
+// Test duplicate files are left out of the totals
+func TestDuplicates(t *testing.T) {
+	result := &Result{Files: Files{
+		File{Path: "a.go", Scanned: true, Hash: "1", Code: 5, Lines: 5},
+		File{Path: "b.go", Scanned: true, Hash: "2", Code: 3, Lines: 3},
+		File{Path: "c.go", Scanned: true, Hash: "1", Code: 5, Lines: 5},
+	}}
+	result.markDuplicates()
+
+	if result.Files[2].Duplicate != "a.go" || result.Files[0].Duplicate != "" {
+		t.Error("Duplicate wrong")
+	}
+	if totals := result.Totals(); totals.Files != 2 || totals.Code != 8 {
+		t.Error("Totals with duplicates wrong")
+	}
+	if len(result.Duplicates()) != 1 {
+		t.Error("Duplicates wrong")
+	}
+}
+
+// Test loading language definitions from a file
+func TestReadLanguages(t *testing.T) {
+	langs, err := ReadLanguages(path + string(os.PathSeparator) + "languages.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keepLanguages()()
+	AddLanguages(langs)
+
+	if lang, found := Detect("widgets.wdg"); !found ||
+		lang.Name != "Widget DSL" || !lang.isComment(";; note") {
+		t.Error("Added language wrong")
+	}
+	if lang, found := Detect("notes.text"); !found || lang.Name != "Text" {
+		t.Error("Replaced language wrong")
+	}
+}
+
+// Test the report carries the totals and language groups
+func TestReport(t *testing.T) {
+	scanner := &Scanner{Include: []string{"*.php", "*.js"}}
+	result, err := scanner.Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(result.Report(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := struct {
+		Schema     int
+		Totals     map[string]int
+		ByLanguage []map[string]interface{}
+		Runtime    float64
+	}{}
+	json.Unmarshal(data, &report)
+	if report.Schema != ReportSchema || report.Runtime != 1 {
+		t.Error("Report header wrong")
+	}
+	if report.Totals["files"] != 2 || report.Totals["code"] != 34 {
+		t.Error("Report totals wrong")
+	}
+	if len(report.ByLanguage) != 2 || report.ByLanguage[1]["name"] != "PHP" {
+		t.Error("Report languages wrong")
+	}
+}
+
+// Test the report encoded as XML and YAML
+func TestReportXMLYAML(t *testing.T) {
+	scanner := &Scanner{Include: []string{"*.php", "*.js"}}
+	result, err := scanner.Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := result.Report(time.Second)
+
+	data, err := xml.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := struct {
+		Schema int `xml:"schema,attr"`
+		Files  []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"files>file"`
+		Totals    Count   `xml:"totals"`
+		Languages []Group `xml:"byLanguage>language"`
+	}{}
+	if err := xml.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Schema != ReportSchema || len(decoded.Files) != 2 || decoded.Totals.Code != 34 {
+		t.Error("XML report totals wrong")
+	}
+	if len(decoded.Languages) != 2 || decoded.Languages[1].Name != "PHP" {
+		t.Error("XML report languages wrong")
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteYAML(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"schema: 1\n", "totals:\n  files: 2\n", "  - name: \"PHP\"\n", "runtime: 1\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("YAML report missing %q", want)
+		}
+	}
+}
+
+// Test the report as Prometheus gauges
+func TestReportPrometheus(t *testing.T) {
+	report := Report{
+		Totals:     Count{Files: 3, Code: 14},
+		ByLanguage: []Group{Group{`C "K&R"`, Count{Files: 2, Code: 10}}},
+		Runtime:    0.5,
+	}
+	var out bytes.Buffer
+	if err := report.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE codecount_code_lines gauge\ncodecount_code_lines 14\n",
+		"codecount_files 3\n",
+		"codecount_code_lines{language=\"C \\\"K&R\\\"\"} 10\n",
+		"codecount_files{language=\"C \\\"K&R\\\"\"} 2\n",
+		"codecount_scan_seconds 0.5\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Prometheus output missing %q", want)
+		}
+	}
+}
+
+// Test scanning again only counts the files that changed
+func TestRescan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.go"), []byte("package b\n"), 0644)
+
+	scanner := &Scanner{}
+	first, err := scanner.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "b.go"), []byte("package b\n\nvar b int\n"), 0644)
+	os.Remove(filepath.Join(dir, "a.go"))
+	ioutil.WriteFile(filepath.Join(dir, "c.go"), []byte("package c\n"), 0644)
+	second, err := scanner.Rescan([]string{dir}, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := second.Changed(first)
+	if len(changed) != 3 || filepath.Base(changed[2]) != "c.go" {
+		t.Errorf("Changed files wrong: %v", changed)
+	}
+	if second.Totals().Code != 3 {
+		t.Errorf("Changed files not scanned: %v", second.Totals())
+	}
+
+	// A count only kept when the file is reused rather than scanned
+	second.Files[1].Code = 20
+	third, err := scanner.Rescan([]string{dir}, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(third.Changed(second)) != 0 || third.Totals().Code != 22 {
+		t.Error("Unchanged files not reused")
+	}
+}
+
+// Test the counts of unchanged files come from the cache
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "a.py")
+	ioutil.WriteFile(source, []byte("# a\nprint(1)\n"), 0644)
+	cachePath := filepath.Join(dir, "cache", "files.json")
+
+	cache, _ := OpenCache(cachePath)
+	if _, err := (&Scanner{Cache: cache}).Scan(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Counts only found in the cache show it was used
+	cache, err = OpenCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, entry := range cache.entries {
+		entry.Code = 10
+		cache.entries[key] = entry
+	}
+	result, _ := (&Scanner{Cache: cache}).Scan(source)
+	if len(result.Files) != 1 || result.Files[0].Code != 10 ||
+		result.Files[0].Lang.Name != "Python" || result.Files[0].Path != source {
+
+		t.Errorf("Cached counts not used: %v", result.Files)
+	}
+	result, _ = (&Scanner{Cache: cache, CountPartial: true}).Scan(source)
+	if result.Files[0].Code != 1 {
+		t.Error("Cached counts used with other options")
+	}
+
+	restore := keepLanguages()
+	AddLanguages(Languages{Language{Name: "Snake", Extension: []string{".py"}}})
+	result, _ = (&Scanner{Cache: cache}).Scan(source)
+	restore()
+	if result.Files[0].Lang.Name != "Snake" || result.Files[0].Code != 2 {
+		t.Error("Cached counts used with other languages")
+	}
+
+	ioutil.WriteFile(source, []byte("# a\nprint(1)\nprint(2)\n"), 0644)
+	result, _ = (&Scanner{Cache: cache}).Scan(source)
+	if result.Files[0].Code != 2 {
+		t.Error("Changed file not scanned")
+	}
+
+	// Files since removed are left out when saved, in a file renamed over
+	// the cache
+	other := filepath.Join(dir, "b.py")
+	ioutil.WriteFile(other, []byte("print(3)\n"), 0644)
+	(&Scanner{Cache: cache}).Scan(other)
+	os.Remove(source)
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	cache, _ = OpenCache(cachePath)
+	if len(cache.entries) != 1 {
+		t.Errorf("Removed files kept: %d entries", len(cache.entries))
+	}
+	if left, _ := filepath.Glob(cachePath + ".*"); len(left) != 0 {
+		t.Errorf("Files left writing the cache: %v", left)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Error("Cache file not removed")
+	}
+}
+
+// Test counting the files inside zip and tar archives
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entries := []struct{ name, body string }{
+		{"app/main.go", "package main\n\n// Run\nfunc main() {}\n"},
+		{"app/run", "#!/usr/bin/env python\nprint(1)\n"},
+		{"app/.git/hooks.sh", "echo hidden\n"},
+		{"app/vendor/lib.go", "package lib\n"},
+		{"app/logo.bin", "not code\n"},
+		{"app/dump.sql", strings.Repeat("INSERT INTO t VALUES (1);\n", 10)},
+	}
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for _, entry := range entries {
+		w, _ := zw.Create(entry.name)
+		w.Write([]byte(entry.body))
+	}
+	zw.Close()
+	ioutil.WriteFile(filepath.Join(dir, "app.zip"), zipped.Bytes(), 0644)
+
+	var tarred bytes.Buffer
+	gz := gzip.NewWriter(&tarred)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, entry := range entries {
+		tw.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(entry.body))})
+		tw.Write([]byte(entry.body))
+	}
+	tw.Close()
+	gz.Close()
+	ioutil.WriteFile(filepath.Join(dir, "app.tar.gz"), tarred.Bytes(), 0644)
+
+	for _, name := range []string{"app.zip", "app.tar.gz"} {
+		archive := filepath.Join(dir, name)
+		result, err := (&Scanner{Exclude: []string{"vendor/"}, MaxFileSize: 100}).Scan(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		totals := result.Totals()
+		if totals.Files != 2 || totals.Code != 3 || totals.Comments != 2 {
+			t.Errorf("%s counted wrong: %v", name, totals)
+		}
+		if large := result.Large(); len(large) != 1 || large[0].Lang.Name != "SQL" {
+			t.Errorf("%s large entries wrong: %v", name, large)
+		}
+		if result.Files[0].Path != filepath.Join(archive, "app", "main.go") {
+			t.Errorf("%s path wrong: %s", name, result.Files[0].Path)
+		}
+	}
+}
+
+// Test splitting the url of a remote repository from its ref
+func TestParseRemote(t *testing.T) {
+	for _, test := range []struct{ arg, url, ref string }{
+		{"https://github.com/org/repo", "https://github.com/org/repo", ""},
+		{"https://github.com/org/repo.git@v1.2", "https://github.com/org/repo.git", "v1.2"},
+		{"https://user@host.com/org/repo@feature/x", "https://user@host.com/org/repo", "feature/x"},
+		{"git@github.com:org/repo.git@1a2b3c", "git@github.com:org/repo.git", "1a2b3c"},
+		{"file:///srv/repo", "file:///srv/repo", ""},
+	} {
+		url, ref, ok := ParseRemote(test.arg)
+		if !ok || url != test.url || ref != test.ref {
+			t.Errorf("Remote %s split wrong: %s %s", test.arg, url, ref)
+		}
+	}
+	for _, arg := range []string{"src/repo", "repo@main", "C:/src"} {
+		if _, _, ok := ParseRemote(arg); ok {
+			t.Errorf("Local path %s taken as remote", arg)
+		}
+	}
+	if dir, err := GitClone("file:///srv/repo", "--upload-pack=touch"); err == nil {
+		os.RemoveAll(dir)
+		t.Error("Ref taken as an option")
+	}
+}
+
+// Test counting only the files listed
+func TestScanFiles(t *testing.T) {
+	scanner := &Scanner{}
+	result, err := scanner.ScanFiles([]string{
+		filepath.Join(path, "lua.lua"),
+		filepath.Join(path, "deleted.lua"),
+		path,
+		filepath.Join(path, "strings.c"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 2 || result.Files[1].Path != filepath.Join(path, "strings.c") {
+		t.Errorf("Listed files wrong: %v", result.Files)
+	}
+}
+
+// Test counting content that is not in a file
+func TestScanReader(t *testing.T) {
+	lang, found := FindLanguage("go")
+	if !found || lang.Name != "Go" {
+		t.Fatal("Language not found by name")
+	}
+	scanner := &Scanner{}
+	result, err := scanner.ScanReader(strings.NewReader("package a\n\n// A\nvar a int\n"), "stdin", lang)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := result.Totals(); count.Files != 1 || count.Code != 2 || count.Comments != 1 || count.Blanks != 1 {
+		t.Errorf("Content counted wrong: %v", count)
+	}
+
+	result, _ = scanner.ScanReader(strings.NewReader("#!/bin/sh\necho a\n"), "stdin", Language{})
+	if result.Files[0].Lang.Name != "Shell" || result.Totals().Code != 1 {
+		t.Error("Content language not detected")
+	}
+	result, _ = scanner.ScanReader(strings.NewReader("a = 1\n"), "stdin", Language{})
+	if len(result.Files) != 0 {
+		t.Error("Content of unknown language counted")
+	}
+	if _, found := FindLanguage("klingon"); found {
+		t.Error("Unknown language found")
+	}
+}
+
+// Test reading the settings of a configuration file
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, ".codecount.toml")
+	ioutil.WriteFile(config, []byte(`# Shared settings
+sort = "code" # largest first
+json = true
+by-dir = 2
+exclude = [
+	"vendor/",
+	'*.pb.go', # generated
+]
+omit = "a#b"
+
+[languages]
+".inc" = "PHP"
+`), 0644)
+
+	settings, err := ReadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"sort":           "code",
+		"json":           "true",
+		"by-dir":         "2",
+		"exclude":        "vendor/|*.pb.go",
+		"omit":           "a#b",
+		"languages..inc": "PHP",
+	}
+	if len(settings) != len(want) {
+		t.Errorf("Settings wrong: %v", settings)
+	}
+	for key, value := range want {
+		if strings.Join(settings[key], "|") != value {
+			t.Errorf("Setting %s wrong: %v", key, settings[key])
+		}
+	}
+
+	ioutil.WriteFile(config, []byte("sort\n"), 0644)
+	if _, err := ReadConfig(config); err == nil {
+		t.Error("Line without a value read")
+	}
+}
+
+// Test files of binary content are skipped
+func TestBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "nul.js"), []byte("var a = 1;\x00\x01\x02\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "noise.js"), []byte("\xff\xfe\x80\x81\x82\x83\x84\x85\x86\x87\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "utf8.js"), []byte("// caf\xc3\xa9\nvar a = 1;\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaries := result.Binaries()
+	if len(binaries) != 2 || filepath.Base(binaries[0].Path) != "noise.js" {
+		t.Errorf("Binary files wrong: %v", binaries.Paths())
+	}
+	if totals := result.Totals(); totals.Files != 1 || totals.Code != 1 {
+		t.Errorf("Text files counted wrong: %v", totals)
+	}
+	if report := result.Report(0); len(report.Binary) != 2 {
+		t.Error("Binary files not reported")
+	}
+}
+
+// Test files written by tools are kept out of the totals
+func TestGenerated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "api.pb.go"), []byte("package main\nvar a int\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "enum.go"),
+		[]byte("// Code generated by stringer; DO NOT EDIT.\n\npackage main\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "app.min.js"), []byte("var a=1;\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 1 || totals.Code != 1 {
+		t.Errorf("Totals hold generated files: %v", totals)
+	}
+	generated := result.Generated()
+	if len(generated) != 3 || generated.Totals().Code != 4 {
+		t.Errorf("Generated files wrong: %v", generated.Paths())
+	}
+	if report := result.Report(0); report.Generated == nil || report.Generated.Files != 3 {
+		t.Error("Generated files not reported")
+	}
+
+	result, _ = (&Scanner{IncludeGenerated: true}).Scan(dir)
+	if totals := result.Totals(); totals.Files != 4 || len(result.Generated()) != 0 {
+		t.Errorf("Generated files not included: %v", totals)
+	}
+}
+
+// Test files of dependencies are kept out of the totals
+func TestVendored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"main.go", "vendor/lib/lib.go", "web/node_modules/a/index.js", "deps/x.go"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte("var a = \""+name+"\"\n"), 0644)
+	}
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 2 {
+		t.Errorf("Totals hold vendored files: %v", totals)
+	}
+	if vendored := result.Vendored(); len(vendored) != 2 {
+		t.Errorf("Vendored files wrong: %v", vendored.Paths())
+	}
+
+	result, _ = (&Scanner{VendorDirs: []string{"deps"}}).Scan(dir)
+	if vendored := result.Vendored(); len(vendored) != 1 || filepath.Base(vendored[0].Path) != "x.go" {
+		t.Errorf("Vendored directories not configured: %v", vendored.Paths())
+	}
+	result, _ = (&Scanner{IncludeVendored: true}).Scan(dir)
+	if totals := result.Totals(); totals.Files != 4 {
+		t.Errorf("Vendored files not included: %v", totals)
+	}
+}
+
+// Test links to directories are skipped, or followed once each
+func TestSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root, outside := filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	for _, name := range []string{"root/src/a.go", "outside/lib/b.go", "outside/c.go"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte("var a = \""+name+"\"\n"), 0644)
+	}
+	links := map[string]string{
+		"src/back": root,
+		"ext":      filepath.Join(outside, "lib"),
+		"ext2":     filepath.Join(outside, "lib"),
+		"c.go":     filepath.Join(outside, "c.go"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skip("Links not supported:", err)
+		}
+	}
+
+	result, err := (&Scanner{}).Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 2 || result.Links != 3 {
+		t.Errorf("Linked directories not skipped: %v %d", result.Files.Paths(), result.Links)
+	}
+
+	result, err = (&Scanner{FollowSymlinks: true}).Scan(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 3 || result.Links != 2 {
+		t.Errorf("Linked directories not followed once: %v %d", result.Files.Paths(), result.Links)
+	}
+}
+
+// Test lines longer than a bufio.Scanner allows are counted and large
+// files are skipped
+func TestLargeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	long := "var a = \"" + strings.Repeat("x", 100000) + "\"\n"
+	ioutil.WriteFile(filepath.Join(dir, "long.go"), []byte("// long\n"+long+"var b = 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "small.go"), []byte("var c = 1\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 2 || totals.Code != 3 || totals.Comments != 1 {
+		t.Errorf("Long lines not counted: %v", totals)
+	}
+
+	result, err = (&Scanner{MaxFileSize: 1024}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if large := result.Large(); len(large) != 1 || filepath.Base(large[0].Path) != "long.go" {
+		t.Errorf("Large file not skipped: %v", large.Paths())
+	}
+	if totals := result.Totals(); totals.Files != 1 {
+		t.Errorf("Large file counted: %v", totals)
+	}
+}
+
+// Test empty and whitespace only files are counted, and not as duplicates
+func TestEmptyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.py"), nil, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.py"), nil, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "c.py"), []byte("\n  \n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "d.py"), []byte("x = 1\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 4 || totals.Blanks != 2 || totals.Code != 1 {
+		t.Errorf("Empty files not counted: %v", totals)
+	}
+	if empty := result.Empty(); len(empty) != 3 {
+		t.Errorf("Empty files wrong: %v", empty.Paths())
+	}
+	if duplicates := result.Duplicates(); len(duplicates) != 0 {
+		t.Errorf("Empty files counted as duplicates: %v", duplicates.Paths())
+	}
+}
+
+// Test byte order marks are dropped, UTF-16 is decoded and content
+// that is not valid UTF-8 is skipped
+func TestEncodings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	utf16 := func(text string, big bool) []byte {
+		data := []byte{0xff, 0xfe}
+		if big {
+			data = []byte{0xfe, 0xff}
+		}
+		for _, r := range text {
+			if big {
+				data = append(data, 0, byte(r))
+			} else {
+				data = append(data, byte(r), 0)
+			}
+		}
+		return data
+	}
+	ioutil.WriteFile(filepath.Join(dir, "bom.go"), []byte("\xef\xbb\xbf// bom\nvar a = 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "le.go"), utf16("// le\nvar b = 1\n", false), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "be.go"), utf16("// be\nvar c = 1\n", true), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "latin1.go"), []byte("// caf\xe9\nvar d = 1\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Files != 3 || totals.Comments != 3 || totals.Code != 3 {
+		t.Errorf("Encodings not counted: %v", totals)
+	}
+	for _, file := range result.Files {
+		want := map[string]string{"le.go": "UTF-16LE", "be.go": "UTF-16BE"}[filepath.Base(file.Path)]
+		if file.Encoding != want {
+			t.Errorf("Encoding of %s is %q", file.Path, file.Encoding)
+		}
+	}
+	if invalid := result.Invalid(); len(invalid) != 1 || filepath.Base(invalid[0].Path) != "latin1.go" {
+		t.Errorf("Invalid file not skipped: %v", invalid.Paths())
+	}
+
+	// A character split between reads is still valid
+	checker := &utf8Checker{}
+	checker.Write([]byte("caf\xc3"))
+	checker.Write([]byte("\xa9 \xe2\x82"))
+	checker.Write([]byte("\xac"))
+	if !checker.Valid() {
+		t.Error("Split characters taken as invalid")
+	}
+	checker.Write([]byte("\xe2\x82"))
+	if checker.Valid() {
+		t.Error("Cut character taken as valid")
+	}
+}
+
+// Test lines ended by CR alone or a mix of endings are split
+func TestLineEndings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"lf.c":    "// lf\nint a;\n\nint b;",
+		"crlf.c":  "// crlf\r\nint c;\r\n\r\nint d;\r\n",
+		"cr.c":    "// cr\rint e;\r\rint f;\r",
+		"mixed.c": "// mixed\r\nint g;\r\rint h;\r\n",
+	}
+	want := map[string]string{"lf.c": "LF", "crlf.c": "CRLF", "cr.c": "CR", "mixed.c": "CRLF"}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range result.Files {
+		name := filepath.Base(file.Path)
+		if file.Lines != 4 || file.Comments != 1 || file.Blanks != 1 || file.Code != 2 {
+			t.Errorf("Lines of %s counted wrong: %v", name, file.count())
+		}
+		if file.LineEnding != want[name] {
+			t.Errorf("Line ending of %s is %q", name, file.LineEnding)
+		}
+	}
+}
+
+// Test documentation comments are told from other comments
+func TestDocComments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"doc.go": "// Package doc\npackage doc\n\n// Add adds\n// two numbers\nfunc Add() {}\n\n" +
+			"// not documentation\n\nvar a = 1 // trailing\n",
+		"doc.rs":   "//! Crate docs\n/// Add adds\nfn add() {}\n// plain\n//// also plain\n",
+		"Doc.java": "/**\n * Add adds\n */\nint add();\n/* plain\n * block\n */\n/** one line */\n",
+		"doc.py":   "def add():\n    \"\"\"Add adds\n    two numbers\"\"\"\n    # plain\n    return 1\n",
+	}
+	want := map[string]int{"doc.go": 3, "doc.rs": 2, "Doc.java": 4, "doc.py": 2}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range result.Files {
+		if name := filepath.Base(file.Path); file.Docs != want[name] {
+			t.Errorf("Doc comments of %s are %d, want %d", name, file.Docs, want[name])
+		}
+	}
+	if totals := result.Totals(); totals.Docs != 11 {
+		t.Errorf("Doc comments totalled wrong: %v", totals)
+	}
+}
+
+// Test branches are counted in code only when measuring complexity
+func TestComplexity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("// if for while\nfunc f() {\n"+
+		"\tif a && b || c {\n\t\ts := \"if for\"\n\t}\n\tfor x := range y { // if\n\t}\n"+
+		"\tswitch {\n\tcase true:\n\t}\n\tiffy := 1\n}\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "a.py"), []byte("if a and b:\n    pass\nelif c or d:\n    pass\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Complexity != 0 {
+		t.Errorf("Complexity measured when not asked: %v", totals)
+	}
+
+	result, err = (&Scanner{Complexity: true}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"a.go": 5, "a.py": 4}
+	for _, file := range result.Files {
+		if name := filepath.Base(file.Path); file.Complexity != want[name] {
+			t.Errorf("Complexity of %s is %d, want %d", name, file.Complexity, want[name])
+		}
+	}
+}
+
+// Test the basic COCOMO estimate of the organic model
+func TestEstimateCost(t *testing.T) {
+	estimate := EstimateCost(10000, 10000)
+	if math.Abs(estimate.Effort-26.93) > 0.01 || math.Abs(estimate.Schedule-8.74) > 0.01 {
+		t.Errorf("Estimate wrong: %+v", estimate)
+	}
+	if math.Abs(estimate.People-estimate.Effort/estimate.Schedule) > 1e-9 ||
+		math.Abs(estimate.Cost-estimate.Effort*10000) > 1e-6 {
+
+		t.Errorf("Estimate people or cost wrong: %+v", estimate)
+	}
+	if estimate := EstimateCost(0, 10000); estimate != (Estimate{}) {
+		t.Errorf("Estimate of no code: %+v", estimate)
+	}
+}
+
+// Test the longest and average line lengths are counted in characters
+func TestLineLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("var a = 1\n\n// caf\u00e9 au lait\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.go"), []byte("var b = \"a longer line of code\"\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range result.Files {
+		want := map[string][2]int{"a.go": {15, 8}, "b.go": {31, 31}}[filepath.Base(file.Path)]
+		if count := file.count(); count.Longest != want[0] || count.Average() != want[1] {
+			t.Errorf("Line lengths of %s are %d and %d, want %v", file.Path,
+				count.Longest, count.Average(), want)
+		}
+	}
+	if totals := result.Totals(); totals.Longest != 31 || totals.Average() != 14 {
+		t.Errorf("Line lengths totalled wrong: %v", totals)
+	}
+}
+
+// Test the size of the files is totalled and always given in JSON
+func TestBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("var a = 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.py"), []byte("b = 22\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals := result.Totals(); totals.Bytes != 17 {
+		t.Errorf("Bytes totalled wrong: %v", totals)
+	}
+	for _, group := range result.ByLanguage() {
+		if want := map[string]int64{"Go": 10, "Python": 7}[group.Name]; group.Bytes != want {
+			t.Errorf("Bytes of %s are %d, want %d", group.Name, group.Bytes, want)
+		}
+	}
+
+	data, _ := json.Marshal(Count{})
+	if !strings.Contains(string(data), `"bytes":0`) {
+		t.Errorf("Bytes left out of JSON: %s", data)
+	}
+}
+
+// Test definitions of functions and methods are counted when asked
+func TestFunctions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.go": "package a\n\n// func in a comment\nfunc A() {}\n\nfunc (t *T) B() {\n\tf := func() {}\n}\n",
+		"a.py": "def a():\n    pass\n\nclass B:\n    async def b(self):\n        return a()\n",
+		"A.java": "class A {\n  public static void main(String[] args) {\n    if (x) {\n" +
+			"      foo(1);\n    }\n    return bar(\n      2);\n  }\n  private int b() { return 1; }\n}\n",
+		"a.js": "function a() {}\nconst b = (x) => x;\nclass C {\n  render() {\n    if (x) {\n    }\n  }\n}\n",
+		"a.c":  "#include <stdio.h>\nstatic int main(void)\n{\n  while (1) {\n  }\n  printf(\"x\");\n}\n",
+	}
+	want := map[string]int{"a.go": 2, "a.py": 2, "A.java": 2, "a.js": 3, "a.c": 1}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	result, err := (&Scanner{Functions: true}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range result.Files {
+		if name := filepath.Base(file.Path); file.Functions != want[name] {
+			t.Errorf("Functions of %s are %d, want %d", name, file.Functions, want[name])
+		}
+	}
+	if totals := result.Totals(); totals.Functions != 10 {
+		t.Errorf("Functions totalled wrong: %v", totals)
+	}
+}
+
+// Test ranges of revisions are split into their ends as git reads them
+func TestSplitRange(t *testing.T) {
+	tests := []struct {
+		revisions, older, newer string
+		base                    bool
+	}{
+		{"main...HEAD", "main", "HEAD", true},
+		{"main...", "main", "HEAD", true},
+		{"v1..v2", "v1", "v2", false},
+		{"..v2", "HEAD", "v2", false},
+		{"HEAD~3", "HEAD~3", "", false},
+	}
+	for _, test := range tests {
+		older, newer, base := splitRange(test.revisions)
+		if older != test.older || newer != test.newer || base != test.base {
+			t.Errorf("Range %s split to %q %q %t", test.revisions, older, newer, base)
+		}
+	}
+}
+
+// Test serving counts of directories below the root
+func TestServer(t *testing.T) {
+	server := &Server{Scanner: &Scanner{}, Root: ".", MaxAge: time.Minute}
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	w := get("/count?path=test_files&format=json")
+	report := Report{}
+	if w.Code != 200 || json.Unmarshal(w.Body.Bytes(), &report) != nil || report.Totals.Files == 0 {
+		t.Fatalf("Count failed: %d %s", w.Code, w.Body)
+	}
+	if len(server.cache) != 1 {
+		t.Error("Count not cached")
+	}
+	if w := get("/metrics?path=test_files"); !strings.Contains(w.Body.String(), "codecount_code_lines{") {
+		t.Error("Metrics wrong")
+	}
+	if len(server.cache) != 1 {
+		t.Error("Cached count not reused")
+	}
+	if w := get("/count?path=../../test_files"); w.Code != 200 {
+		t.Error("Path not kept within the root")
+	}
+	if w := get("/count?path=missing"); w.Code != 404 {
+		t.Errorf("Missing path answered %d", w.Code)
+	}
+	if w := get("/count?format=toml"); w.Code != 400 {
+		t.Errorf("Unknown format answered %d", w.Code)
+	}
+	if len(server.cache) != 1 {
+		t.Errorf("Failed paths kept: %d reports", len(server.cache))
+	}
+
+	// A directory being scanned holds up only the requests for it
+	slow := &cachedReport{}
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	server.cache[server.resolve("slow")] = slow
+	done := make(chan int)
+	go func() { done <- get("/count?path=test_files").Code }()
+	select {
+	case code := <-done:
+		if code != 200 {
+			t.Errorf("Count answered %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Count held up by the scan of another directory")
+	}
+}
+
+// Test the totals by directory cut to a depth
+func TestByDir(t *testing.T) {
+	result := &Result{Files: Files{
+		File{Path: "root/main.go", Scanned: true, Code: 1},
+		File{Path: "root/pkg/api/a.go", Scanned: true, Code: 2},
+		File{Path: "root/pkg/api/v1/b.go", Scanned: true, Code: 4},
+		File{Path: "root/pkg/db/c.go", Scanned: true, Code: 8},
+	}}
+
+	groups := result.ByDir("root", 2)
+	want := map[string]int{"./": 1, "pkg/api/": 6, "pkg/db/": 8}
+	if len(groups) != len(want) {
+		t.Fatal("Directories wrong")
+	}
+	for _, group := range groups {
+		if want[group.Name] != group.Code {
+			t.Errorf("Directory %s wrong", group.Name)
+		}
+	}
+}
+
+// Test the HTML report holds the languages, directories and chart
+func TestWriteHTML(t *testing.T) {
+	result := &Result{Files: Files{
+		File{Path: "root/main.go", Lang: Language{Name: "Go"}, Scanned: true, Code: 3, Lines: 3},
+		File{Path: "root/web/<app>.js", Lang: Language{Name: "Javascript"}, Scanned: true, Code: 1, Lines: 1},
+	}}
+	var out bytes.Buffer
+	if err := result.WriteHTML(&out, "root"); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{"<td>Go</td>", "<summary>web/", "&lt;app&gt;.js", "Javascript 25%", "<path d="} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML report missing %s", want)
+		}
+	}
+}
+
+// Test scanning several roots and totalling by root
+func TestScanAll(t *testing.T) {
+	lua, c := filepath.Join(path, "lua.lua"), filepath.Join(path, "strings.c")
+	result, err := (&Scanner{}).ScanAll([]string{lua, c, lua})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := result.ByRoot()
+	if len(groups) != 2 || groups[0].Name != lua || groups[0].Files != 1 || groups[1].Name != c {
+		t.Errorf("Roots wrong: %v", groups)
+	}
+	if len(result.Files) != 3 || result.Files[2].Duplicate != lua {
+		t.Error("Duplicates across roots not found")
+	}
+}
+
+// Test limits on the totals
+func TestCheck(t *testing.T) {
+	count := Count{Files: 3, Code: 120, Lines: 200}
+	for _, test := range []struct {
+		expr  string
+		holds bool
+	}{
+		{"code > 100", true},
+		{"code>=120", true},
+		{"lines < 200", false},
+		{"files == 3", true},
+		{"comments != 0", false},
+	} {
+		check, err := ParseCheck(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if check.Holds(count) != test.holds {
+			t.Errorf("Limit %s wrong", test.expr)
+		}
+	}
+	for _, expr := range []string{"code >", "size > 1", "code ~ 1"} {
+		if _, err := ParseCheck(expr); err == nil {
+			t.Errorf("Limit %s parsed", expr)
+		}
+	}
+
+	result := &Result{Files: Files{
+		File{Path: "a", Scanned: true, Lines: 10},
+		File{Path: "b", Scanned: true, Lines: 30},
+	}}
+	if over := result.FilesOver(20); len(over) != 1 || over[0].Path != "b" {
+		t.Error("Files over the limit wrong")
+	}
+}
+
+// Test limits are written as JUnit XML with a failure for each failed
+func TestJUnit(t *testing.T) {
+	result := &Result{Files: Files{
+		File{Path: "a", Scanned: true, Lines: 10, Code: 10},
+		File{Path: "b", Scanned: true, Lines: 30, Code: 30},
+	}}
+	checks := []Check{{"code", ">", 100}, {"code", ">", 20}}
+	outcomes := result.CheckLimits(checks, 20)
+	if len(outcomes) != 4 || outcomes[0].Failure != "" ||
+		outcomes[1].Failure != "code > 20, code is 40" ||
+		outcomes[3].Failure != "b has 30 lines, more than 20" {
+
+		t.Fatalf("Outcomes wrong: %v", outcomes)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteJUnit(buf, outcomes); err != nil {
+		t.Fatal(err)
+	}
+	report := junitSuites{}
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 4 || report.Failures != 2 || len(report.Suites) != 2 {
+		t.Errorf("JUnit totals wrong: %s", buf)
+	}
+	if suite := report.Suites[1]; suite.Name != "max-file-lines" || suite.Failures != 1 ||
+		suite.Cases[1].Failure == nil || suite.Cases[0].Failure != nil {
+
+		t.Errorf("JUnit suite wrong: %s", buf)
+	}
+}
+
+// Test ordering the groups by a column
+func TestSortGroups(t *testing.T) {
+	groups := []Group{
+		Group{"b", Count{Code: 1, Lines: 5}},
+		Group{"a", Count{Code: 3, Lines: 4}},
+		Group{"c", Count{Code: 2, Lines: 6}},
+	}
+	for _, test := range []struct {
+		by      string
+		reverse bool
+		want    string
+	}{
+		{"code", false, "acb"},
+		{"lines", false, "cba"},
+		{"name", false, "abc"},
+		{"name", true, "cba"},
+	} {
+		SortGroups(groups, test.by, test.reverse)
+		order := ""
+		for _, group := range groups {
+			order += group.Name
+		}
+		if order != test.want {
+			t.Errorf("Sort by %s wrong: %s", test.by, order)
+		}
+	}
+	if SortGroups(groups, "size", false) == nil {
+		t.Error("Unknown column not refused")
+	}
+}
+
+// Test keeping only the largest groups
+func TestTrimGroups(t *testing.T) {
+	groups := []Group{
+		Group{"a", Count{Files: 1, Lines: 5}},
+		Group{"b", Count{Files: 1, Lines: 50}},
+		Group{"c", Count{Files: 1, Lines: 20}},
+		Group{"d", Count{Files: 1, Lines: 1}},
+	}
+	trimmed := TrimGroups(groups, 2, 0)
+	if len(trimmed) != 3 || trimmed[0].Name != "b" || trimmed[1].Name != "c" {
+		t.Errorf("Top groups wrong: %v", trimmed)
+	}
+	if trimmed[2].Name != "(2 more)" || trimmed[2].Files != 2 || trimmed[2].Lines != 6 {
+		t.Errorf("Hidden groups wrong: %v", trimmed[2])
+	}
+	trimmed = TrimGroups(groups, 0, 5)
+	if len(trimmed) != 4 || trimmed[3].Name != "(1 more)" {
+		t.Errorf("Minimum lines wrong: %v", trimmed)
+	}
+	if len(TrimGroups(groups, 0, 0)) != 4 {
+		t.Error("Groups trimmed without limits")
+	}
+}
+
+// Test the lines added and removed between two versions of a file
+func TestDiffFile(t *testing.T) {
+	older := &File{classified: []classifiedLine{
+		{"// old", commentLine}, {"x := 1", codeLine}, {"", blankLine}}}
+	newer := &File{classified: []classifiedLine{
+		{"x := 1", codeLine}, {"", blankLine}, {"y := 2", codeLine}, {"z := 3", codeLine}}}
+
+	change := diffFile(older, newer)
+	if change.Added.Code != 2 || change.Added.Lines != 2 ||
+		change.Removed.Comments != 1 || change.Removed.Lines != 1 {
+		t.Error("Changed file wrong")
+	}
+	if change := diffFile(nil, newer); change.Added.Files != 1 || change.Added.Lines != 4 {
+		t.Error("Added file wrong")
+	}
+}
+
+// Test the progress is told of each file counted
+func TestProgress(t *testing.T) {
+	lines := 0
+	seen := []string{}
+	scanner := &Scanner{Progress: func(file File) {
+		seen = append(seen, file.Path)
+		lines += file.Lines
+	}}
+	result, err := scanner.Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != len(result.Files) || lines == 0 {
+		t.Errorf("Progress told of %d files, %d counted", len(seen), len(result.Files))
+	}
+}
+
+// Test a scan stopped by its context keeps the files counted before
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	scanner := &Scanner{Context: ctx}
+	scanner.Progress = func(file File) { cancel() }
+	result, err := scanner.ScanAll([]string{path, path})
+	if err != context.Canceled {
+		t.Fatalf("Scan not stopped: %v", err)
+	}
+	if !result.Partial || len(result.Files) != 1 || !result.Report(0).Partial {
+		t.Errorf("Partial result wrong: %d files", len(result.Files))
+	}
+
+	result, err = scanner.ScanFiles([]string{filepath.Join(path, "php.php")})
+	if err != context.Canceled || len(result.Files) != 0 || !result.Partial {
+		t.Errorf("Listed files not stopped: %v", err)
+	}
+
+	file := File{Path: "large.go", Lang: languageNamed("Go")}
+	lines := strings.NewReader(strings.Repeat("x := 1\n", 3*cancelLines))
+	if err := scanner.scanLines(&file, lines); err != context.Canceled || file.Lines >= cancelLines {
+		t.Errorf("Large file not stopped: %v after %d lines", err, file.Lines)
+	}
+}
+
+// Test streaming folds the files into the same totals as keeping them
+func TestStream(t *testing.T) {
+	roots := []string{path, path}
+	kept, err := (&Scanner{}).ScanAll(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folded, err := (&Scanner{Stream: true}).ScanAll(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folded.Summary == nil || len(folded.Files) != len(kept.Binaries())+len(kept.Invalid())+len(kept.Large()) {
+		t.Fatalf("Files kept when streaming: %d", len(folded.Files))
+	}
+	if folded.Totals() != kept.Totals() {
+		t.Errorf("Totals differ: %v, %v", folded.Totals(), kept.Totals())
+	}
+	if fmt.Sprint(folded.ByLanguage()) != fmt.Sprint(kept.ByLanguage()) {
+		t.Errorf("Languages differ: %v, %v", folded.ByLanguage(), kept.ByLanguage())
+	}
+	if folded.Summary.Duplicates != kept.Duplicates().Totals() ||
+		folded.GeneratedTotals() != kept.GeneratedTotals() ||
+		folded.VendoredTotals() != kept.VendoredTotals() ||
+		folded.EmptyTotals() != kept.EmptyTotals() {
+
+		t.Error("Files kept out of the totals differ")
+	}
+}
+
+// Test an inventory counts the files and bytes of each language, kept or
+// streamed, without reading the lines
+func TestInventory(t *testing.T) {
+	listed, err := (&Scanner{Inventory: true}).Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals := listed.Totals()
+	if totals.Files != len(listed.Files) || totals.Bytes == 0 || totals.Lines != 0 ||
+		listed.EmptyTotals().Files != 0 {
+
+		t.Errorf("Inventory totals wrong: %v", totals)
+	}
+	languages, sum := listed.ByLanguage(), Count{}
+	for _, lang := range languages {
+		sum.Add(lang.Count)
+	}
+	if len(languages) == 0 || sum != totals {
+		t.Errorf("Inventory languages wrong: %v", languages)
+	}
+	report := listed.Report(0)
+	if report.Totals != totals || len(report.Files) != totals.Files {
+		t.Errorf("Inventory report wrong: %v", report.Totals)
+	}
+
+	folded, err := (&Scanner{Inventory: true, Stream: true}).Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folded.Totals() != totals || fmt.Sprint(folded.ByLanguage()) != fmt.Sprint(languages) {
+		t.Errorf("Streamed inventory differs: %v", folded.Totals())
+	}
+}
+
+// Test a directory that cannot be read is skipped rather than ending the scan
+func TestUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "closed"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "closed", "a.py"), []byte("a = 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.py"), []byte("b = 2\n"), 0644)
+	os.Chmod(filepath.Join(dir, "closed"), 0)
+	defer os.Chmod(filepath.Join(dir, "closed"), 0755)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan ended: %v", err)
+	}
+	if totals := result.Totals(); totals.Files != 1 || totals.Code != 1 {
+		t.Errorf("Readable files not counted: %v", totals)
+	}
+	if _, err := (&Scanner{}).Scan(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Missing root not an error")
+	}
+}
+
+// Test the walker visits the same paths in the same order as filepath.Walk
+func TestWalker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b/x/1.go", "b/y/2.go", "a/3.go", "c/skip/deep/4.go", "c/5.go", ".git/6.go"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+	}
+
+	visit := func(walk func(string, filepath.WalkFunc) error) []string {
+		paths := []string{}
+		walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			if info.IsDir() && info.Name() == "skip" {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		return paths
+	}
+	want := visit(filepath.Walk)
+	w := newWalker()
+	defer w.Stop()
+	if got := visit(w.Walk); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Walked %v, want %v", got, want)
+	}
+	// A directory skipped has nothing below it read ahead
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.ahead) != 0 {
+		t.Errorf("Directories read ahead and not walked: %v", w.ahead)
+	}
+}
+
+// A reporter keeping what it is given
+type testReporter struct {
+	started bool
+	files   []string
+	totals  Totals
+}
+
+func (r *testReporter) Start() error               { r.started = true; return nil }
+func (r *testReporter) File(file File) error       { r.files = append(r.files, file.Path); return nil }
+func (r *testReporter) Finish(totals Totals) error { r.totals = totals; return nil }
+
+// Test a registered reporter is given each file then the totals
+func TestReporter(t *testing.T) {
+	kept := &testReporter{}
+	RegisterReporter("Test", func(w io.Writer) Reporter { return kept })
+	defer func() {
+		reportersMu.Lock()
+		delete(reporters, "test")
+		reportersMu.Unlock()
+	}()
+	reporter, err := NewReporter("test", ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := ReporterNames(); strings.Join(names, ",") != "csv,json,test,text" {
+		t.Errorf("Reporter names wrong: %v", names)
+	}
+
+	result := &Result{Links: 2, Files: Files{
+		File{Path: "a.go", Scanned: true, Lang: languageNamed("Go"), Lines: 3, Code: 2, Blanks: 1},
+		File{Path: "b.bin", Binary: true},
+	}}
+	if err := result.Write(reporter, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !kept.started || strings.Join(kept.files, ",") != "a.go,b.bin" {
+		t.Errorf("Files given wrong: %v", kept.files)
+	}
+	totals := kept.totals
+	if totals.Files != 1 || totals.Code != 2 || totals.Binary != 1 || totals.Links != 2 ||
+		totals.Runtime != time.Second || len(totals.ByLanguage) != 1 {
+
+		t.Errorf("Totals given wrong: %+v", totals)
+	}
+	if _, err := NewReporter("none", ioutil.Discard); err == nil {
+		t.Error("Unknown reporter made")
+	}
+}
+
+// Test the reporters of the package write the result as its report does
+func TestReporters(t *testing.T) {
+	result, err := (&Scanner{}).Scan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"text", "json", "csv"} {
+		reporter, err := NewReporter(name, &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("No %s reporter: %v", name, err)
+		}
+		if err := result.Write(reporter, time.Second); err != nil {
+			t.Errorf("Writing %s failed: %v", name, err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	reporter, _ := NewReporter("json", out)
+	result.Write(reporter, time.Second)
+	want, _ := json.Marshal(result.Report(time.Second))
+	if strings.TrimSpace(out.String()) != string(want) {
+		t.Errorf("JSON reporter differs from the report:\n%s\n%s", out, want)
+	}
+
+	out.Reset()
+	reporter, _ = NewReporter("csv", out)
+	result.Write(reporter, 0)
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := records[len(records)-1]
+	if records[0][0] != "Grouping" || last[0] != "Totals" ||
+		last[4] != strconv.Itoa(result.Totals().Code) {
+
+		t.Errorf("CSV totals wrong: %v", last)
+	}
+
+	out.Reset()
+	reporter, _ = NewReporter("text", out)
+	result.Write(reporter, 0)
+	if !strings.Contains(out.String(), "Totals") || !strings.Contains(out.String(), "PHP") {
+		t.Errorf("Text report wrong:\n%s", out)
+	}
+}
+
+// Test a snapshot read back compares to a later scan by file and language
+func TestCompareReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.py"), []byte("a = 1\nb = 2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.py"), []byte("c = 3\n"), 0644)
+
+	result, err := (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(result.Report(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := dir + ".json"
+	defer os.Remove(snapshot)
+	ioutil.WriteFile(snapshot, data, 0644)
+	before, err := ReadReport(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before.Files) != 2 || before.Files[0].Code+before.Files[1].Code != 3 {
+		t.Fatalf("Snapshot not read back: %v", before.Files)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "a.py"), []byte("a = 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "c.go"), []byte("package c\n\nvar x = 1\nvar y = 2\n"), 0644)
+	result, err = (&Scanner{}).Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comparison := CompareReports(before, result.Report(0))
+	if len(comparison.Files) != 2 {
+		t.Fatalf("Changed files wrong: %v", comparison.Files)
+	}
+	if mover := comparison.Files[0]; !strings.HasSuffix(mover.Name, "c.go") ||
+		mover.Net().Code != 3 || mover.Net().Files != 1 {
+
+		t.Errorf("Biggest mover wrong: %v", mover)
+	}
+	if shrunk := comparison.Files[1]; shrunk.Net().Code != -1 || shrunk.Net().Files != 0 {
+		t.Errorf("Shrunk file wrong: %v", shrunk)
+	}
+	if len(comparison.ByLanguage) != 2 || comparison.ByLanguage[0].Name != "Go" ||
+		comparison.ByLanguage[1].Net().Code != -1 {
+
+		t.Errorf("Languages wrong: %v", comparison.ByLanguage)
+	}
+	if net := comparison.Totals.Net(); net.Code != 2 || net.Files != 1 {
+		t.Errorf("Totals wrong: %v", net)
+	}
+}
+
+// Test badges show the count shortened and escape their text
+func TestBadge(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 999: "999", 1234: "1.2k", 7012: "7k",
+		123456: "123k", 1250000: "1.2M", 12000000: "12M"} {
+
+		if got := ShortNumber(n); got != want {
+			t.Errorf("ShortNumber(%d) = %s, want %s", n, got, want)
+		}
+	}
+
+	badge, err := BadgeFor(Count{Code: 123456}, "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(badge, new(struct{})); err != nil {
+		t.Errorf("Badge not valid XML: %v", err)
+	}
+	if !bytes.Contains(badge, []byte("<title>lines of code: 123k</title>")) {
+		t.Errorf("Badge text wrong: %s", badge)
+	}
+	if badge := Badge("a<b", "c&d", "red"); !bytes.Contains(badge, []byte("a&lt;b: c&amp;d")) {
+		t.Errorf("Badge text not escaped: %s", badge)
+	}
+	if _, err := BadgeFor(Count{}, "size"); err == nil {
+		t.Error("Unknown metric not refused")
+	}
+}
+
+// Keep the language table as it is, returning the func that restores it
+// after AddLanguages, which edits the shared table in place
+func keepLanguages() func() {
+	saved, exts, names, hash := append(Languages(nil), languages...), extensions, filenames, languagesHash
+	return func() {
+		languages, extensions, filenames, languagesHash = saved, exts, names, hash
+	}
+}
+
 // Check the scanner and compare against
 // known values for the test
-func check_scan(t *testing.T, filename string, test File) {
-	stats, _ := os.Stat(filename)
-	file := File{path: filename, info: stats}
-	file.scan()
+func check_scan(t *testing.T, filename string, test File) File {
+	return check_scan_with(t, &Scanner{}, filename, test)
+}
+
+// Check the scanner with options set and compare
+// against known values for the test
+func check_scan_with(t *testing.T, scanner *Scanner, filename string, test File) File {
+	file, err := scanner.ScanFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if file.code != test.code {
+	if file.Code != test.Code {
 		t.Error("Code wrong")
 	}
-	if file.lines != test.lines {
+	if file.Lines != test.Lines {
 		t.Error("Lines wrong")
 	}
-	if file.comments != test.comments {
+	if file.Comments != test.Comments {
 		t.Error("Comments wrong")
 	}
-	if file.blanks != test.blanks {
+	if file.Blanks != test.Blanks {
 		t.Error("Blanks wrong")
 	}
 
 	if t.Failed() {
 		printout(file, test)
 	}
+	return file
+}
+
+// Scan a file with debugging on, giving the kind of each line as comment,
+// code or blank, keyed by the line without its indent
+func lineKinds(t *testing.T, filename string) map[string]string {
+	out := &bytes.Buffer{}
+	if _, err := (&Scanner{Debug: out}).ScanFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{}
+	for _, record := range strings.Split(out.String(), "\n") {
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		kind := "code"
+		switch fields[0] {
+		case "BLNK":
+			kind = "blank"
+		case "LCOM", "DCOM", "OCOM", "BCOM", "CCOM", "ECOM", "LITR", "CELL":
+			kind = "comment"
+		}
+		kinds[strings.TrimSpace(fields[2])] = kind
+	}
+	return kinds
 }
 
 // Printout the scan results along with
 // the known values for the test
 func printout(file File, test File) {
 	fmt.Printf("%-29s%10d%10d%10d%10d\n",
-		file.info.Name(),
-		file.blanks,
-		file.comments,
-		file.code,
-		file.lines)
+		file.Info.Name(),
+		file.Blanks,
+		file.Comments,
+		file.Code,
+		file.Lines)
 	fmt.Printf("%-29s%10d%10d%10d%10d\n",
 		"Manual Count....",
-		test.blanks,
-		test.comments,
-		test.code,
-		test.lines)
+		test.Blanks,
+		test.Comments,
+		test.Code,
+		test.Lines)
 }
@@ -0,0 +1,116 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+)
+
+// Delta is the counts of a file or language in two reports
+type Delta struct {
+	Name   string `json:"name"`
+	Before Count  `json:"before"`
+	After  Count  `json:"after"`
+}
+
+// The growth from before to after, negative when it shrank
+func (d Delta) Net() Count {
+	net := d.After
+	net.Sub(d.Before)
+	net.Files -= d.Before.Files
+	return net
+}
+
+// Comparison is the growth between two reports by file and by language
+type Comparison struct {
+	Files      []Delta `json:"files"`
+	ByLanguage []Delta `json:"byLanguage"`
+	Totals     Delta   `json:"totals"`
+}
+
+// Read a report written as JSON, like a snapshot
+func ReadReport(path string) (Report, error) {
+	report := Report{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	err = json.Unmarshal(data, &report)
+	return report, err
+}
+
+// Compare an earlier report to a later one, the files that changed
+// ordered by how much their code moved, most first
+func CompareReports(before Report, after Report) *Comparison {
+	comparison := &Comparison{Totals: Delta{"Totals", before.Totals, after.Totals}}
+
+	files := map[string]*Delta{}
+	names := []string{}
+	delta := func(path string) *Delta {
+		if _, found := files[path]; !found {
+			files[path] = &Delta{Name: path}
+			names = append(names, path)
+		}
+		return files[path]
+	}
+	for _, file := range before.Files {
+		if file.Scanned {
+			delta(file.Path).Before = file.count()
+		}
+	}
+	for _, file := range after.Files {
+		if file.Scanned {
+			delta(file.Path).After = file.count()
+		}
+	}
+	for _, name := range names {
+		if d := *files[name]; d.Before != d.After {
+			comparison.Files = append(comparison.Files, d)
+		}
+	}
+	sort.SliceStable(comparison.Files, func(i, j int) bool {
+		return abs(comparison.Files[i].Net().Code) > abs(comparison.Files[j].Net().Code)
+	})
+
+	langs := map[string]*Delta{}
+	for _, group := range before.ByLanguage {
+		langs[group.Name] = &Delta{Name: group.Name, Before: group.Count}
+	}
+	for _, group := range after.ByLanguage {
+		if _, found := langs[group.Name]; !found {
+			langs[group.Name] = &Delta{Name: group.Name}
+		}
+		langs[group.Name].After = group.Count
+	}
+	for _, d := range langs {
+		comparison.ByLanguage = append(comparison.ByLanguage, *d)
+	}
+	sort.Slice(comparison.ByLanguage, func(i, j int) bool {
+		return comparison.ByLanguage[i].Name < comparison.ByLanguage[j].Name
+	})
+	return comparison
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Keywords and operators taking a branch, by the language; a rough
+// measure of complexity rather than a parse of the code
+var branchWords = map[string][]string{
+	"C":                cBranches,
+	"C++":              cBranches,
+	"C/C++ Header":     cBranches,
+	"C#":               cBranches,
+	"Apex":             cBranches,
+	"D":                cBranches,
+	"Dart":             cBranches,
+	"Go":               cBranches,
+	"Groovy":           cBranches,
+	"Java":             cBranches,
+	"Javascript":       cBranches,
+	"JSX":              cBranches,
+	"Kotlin":           append([]string{"when"}, cBranches...),
+	"Objective-C":      cBranches,
+	"Objective-C++":    cBranches,
+	"Odin":             cBranches,
+	"PHP":              append([]string{"elseif", "foreach"}, cBranches...),
+	"Scala":            cBranches,
+	"Swift":            append([]string{"guard"}, cBranches...),
+	"TSX":              cBranches,
+	"TypeScript":       cBranches,
+	"V":                cBranches,
+	"Zig":              cBranches,
+	"Rust":             {"if", "for", "while", "loop", "&&", "||"},
+	"CoffeeScript":     {"if", "unless", "for", "while", "until", "when", "catch", "and", "or", "&&", "||"},
+	"Elm":              {"if", "case"},
+	"Haskell":          {"if", "case"},
+	"PureScript":       {"if", "case"},
+	"Julia":            {"if", "elseif", "for", "while", "catch", "&&", "||"},
+	"Lua":              {"if", "elseif", "for", "while", "repeat", "and", "or"},
+	"MATLAB":           {"if", "elseif", "for", "while", "case", "catch", "&&", "||"},
+	"Nim":              {"if", "elif", "for", "while", "case", "except", "and", "or"},
+	"Perl":             {"if", "elsif", "unless", "for", "foreach", "while", "until", "and", "or", "&&", "||"},
+	"PowerShell":       {"(?i)", "if", "elseif", "for", "foreach", "while", "catch", "-and", "-or"},
+	"Python":           {"if", "elif", "for", "while", "except", "case", "and", "or"},
+	"R":                {"if", "for", "while", "repeat", "&&", "||"},
+	"Ruby":             {"if", "elsif", "unless", "for", "while", "until", "when", "rescue", "and", "or", "&&", "||"},
+	"Shell":            {"if", "elif", "for", "while", "until", "&&", "||"},
+	"Starlark":         {"if", "elif", "for", "and", "or"},
+	"Batch":            {"(?i)", "if", "for"},
+	"VB":               {"(?i)", "if", "elseif", "for", "while", "case", "catch", "andalso", "orelse"},
+	"Literate Haskell": {"if", "case"},
+}
+
+// Branches of the C family of languages
+var cBranches = []string{"if", "for", "while", "case", "catch", "&&", "||"}
+
+// The branch words of each language as one expression
+var branchPatterns = map[string]*regexp.Regexp{}
+
+// A keyword, matched as a whole word rather than inside another
+var wordPattern = regexp.MustCompile(`^\w+$`)
+
+func init() {
+	for name, words := range branchWords {
+		flags, alternatives := "", []string{}
+		for _, word := range words {
+			switch {
+			case word == "(?i)":
+				flags = word
+			case wordPattern.MatchString(word):
+				alternatives = append(alternatives, `\b`+word+`\b`)
+			default:
+				alternatives = append(alternatives, regexp.QuoteMeta(word))
+			}
+		}
+		branchPatterns[name] = regexp.MustCompile(flags + strings.Join(alternatives, "|"))
+	}
+}
+
+// The number of branches taken in a line of code, leaving out those in
+// strings and a comment after the code
+func (lang Language) branches(line string) int {
+	pattern, found := branchPatterns[lang.Name]
+	if !found {
+		return 0
+	}
+	code, _ := lang.stripStrings(line)
+	for _, marker := range lang.Comment {
+		if i := strings.Index(code, marker); i >= 0 {
+			code = code[:i]
+		}
+	}
+	return len(pattern.FindAllStringIndex(code, -1))
+}
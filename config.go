@@ -0,0 +1,142 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds the settings of a configuration file, each key with its
+// values; keys under a [table] are named table.key
+type Config map[string][]string
+
+// Read the TOML configuration file, keeping to the part of TOML that
+// settings need: strings, numbers, booleans, arrays of these and tables
+func ReadConfig(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config := Config{}
+	table := ""
+	number := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(stripConfigComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, number)
+		}
+		key := unquoteConfig(strings.TrimSpace(line[:eq]))
+		if table != "" {
+			key = table + "." + key
+		}
+		value := strings.TrimSpace(line[eq+1:])
+		// Arrays may continue over the lines until they close
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && scanner.Scan() {
+			number++
+			value += " " + strings.TrimSpace(stripConfigComment(scanner.Text()))
+		}
+
+		values := []string{value}
+		if strings.HasPrefix(value, "[") {
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("%s:%d: array of %s is not closed", path, number, key)
+			}
+			values = splitConfigArray(value[1 : len(value)-1])
+		}
+		for i := range values {
+			values[i] = unquoteConfig(values[i])
+		}
+		config[key] = values
+	}
+	return config, scanner.Err()
+}
+
+// The line without a # comment outside of a string
+func stripConfigComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote != 0 && line[i] == '\\' && quote == '"':
+			i++
+		case quote != 0 && line[i] == quote:
+			quote = 0
+		case quote == 0 && (line[i] == '"' || line[i] == '\''):
+			quote = line[i]
+		case quote == 0 && line[i] == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// The items of an array split at the commas outside of strings
+func splitConfigArray(list string) []string {
+	items := []string{}
+	quote := byte(0)
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch {
+			case quote != 0 && list[i] == '\\' && quote == '"':
+				i++
+				continue
+			case quote != 0 && list[i] == quote:
+				quote = 0
+				continue
+			case quote == 0 && (list[i] == '"' || list[i] == '\''):
+				quote = list[i]
+				continue
+			case quote != 0 || list[i] != ',':
+				continue
+			}
+		}
+		if item := strings.TrimSpace(list[start:i]); item != "" {
+			items = append(items, item)
+		}
+		start = i + 1
+	}
+	return items
+}
+
+// The value of a basic or literal string, other values as written
+func unquoteConfig(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil && value[0] == '"' {
+		return unquoted
+	}
+	return value
+}
@@ -0,0 +1,185 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Classifications of a line
+const (
+	blankLine = iota
+	commentLine
+	codeLine
+)
+
+// A line of a file and its classification, kept for diffing
+type classifiedLine struct {
+	text  string
+	class int
+}
+
+// Change is the lines added and removed between two scans
+type Change struct {
+	Name    string `json:"name"`
+	Added   Count  `json:"added"`
+	Removed Count  `json:"removed"`
+}
+
+// DiffResult is the changes by file and by language between two scans
+type DiffResult struct {
+	Files      []Change `json:"files"`
+	ByLanguage []Change `json:"byLanguage"`
+	Totals     Change   `json:"totals"`
+}
+
+// Scan two trees and compare the files at the same relative paths,
+// counting the lines added and removed by their classification
+func (s *Scanner) Diff(oldRoot string, newRoot string) (*DiffResult, error) {
+	s.keep = true
+	defer func() { s.keep = false }()
+
+	older, err := s.Scan(oldRoot)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := s.Scan(newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return diffResults(oldRoot, older, newRoot, newer), nil
+}
+
+// Pair up the files of two results by relative path and diff them
+func diffResults(oldRoot string, older *Result, newRoot string, newer *Result) *DiffResult {
+	olds := map[string]*File{}
+	for i := 0; i < len(older.Files); i++ {
+		if older.Files[i].Scanned {
+			olds[relative(oldRoot, older.Files[i].Path)] = &older.Files[i]
+		}
+	}
+
+	diff := &DiffResult{Totals: Change{Name: "Totals"}}
+	langs := map[string]*Change{}
+	add := func(name string, lang string, older *File, newer *File) {
+		change := diffFile(older, newer)
+		if change.Added.Lines == 0 && change.Removed.Lines == 0 {
+			return
+		}
+		change.Name = name
+		diff.Files = append(diff.Files, change)
+		if _, found := langs[lang]; !found {
+			langs[lang] = &Change{Name: lang}
+		}
+		for _, total := range []*Change{langs[lang], &diff.Totals} {
+			total.Added.Add(change.Added)
+			total.Removed.Add(change.Removed)
+		}
+	}
+
+	for i := 0; i < len(newer.Files); i++ {
+		if !newer.Files[i].Scanned {
+			continue
+		}
+		rel := relative(newRoot, newer.Files[i].Path)
+		add(rel, newer.Files[i].Lang.Name, olds[rel], &newer.Files[i])
+		delete(olds, rel)
+	}
+	for rel, file := range olds {
+		add(rel, file.Lang.Name, file, nil)
+	}
+
+	sort.Slice(diff.Files, func(i, j int) bool {
+		return diff.Files[i].Name < diff.Files[j].Name
+	})
+	for _, change := range langs {
+		diff.ByLanguage = append(diff.ByLanguage, *change)
+	}
+	sort.Slice(diff.ByLanguage, func(i, j int) bool {
+		return diff.ByLanguage[i].Name < diff.ByLanguage[j].Name
+	})
+	return diff
+}
+
+// Compare the lines of two versions of a file as sets, lines only in
+// the newer are added and lines only in the older are removed. Either
+// may be nil for a file that was added or removed.
+func diffFile(older *File, newer *File) Change {
+	change := Change{}
+	counts := map[classifiedLine]int{}
+	if older != nil {
+		for _, line := range older.classified {
+			counts[line]++
+		}
+	}
+	if newer != nil {
+		for _, line := range newer.classified {
+			if counts[line] > 0 {
+				counts[line]--
+			} else {
+				change.Added.addClass(line.class)
+			}
+		}
+	}
+	for line, count := range counts {
+		for i := 0; i < count; i++ {
+			change.Removed.addClass(line.class)
+		}
+	}
+
+	if older == nil {
+		change.Added.Files = 1
+	} else if newer == nil {
+		change.Removed.Files = 1
+	}
+	return change
+}
+
+// Count a single line of the classification
+func (c *Count) addClass(class int) {
+	switch class {
+	case blankLine:
+		c.Blanks++
+	case commentLine:
+		c.Comments++
+	default:
+		c.Code++
+	}
+	c.Lines++
+}
+
+// Keep the last line read, classified by which count it moved
+func (file *File) keep(text string, before Count) {
+	class := codeLine
+	if file.Blanks > before.Blanks {
+		class = blankLine
+	} else if file.Comments > before.Comments {
+		class = commentLine
+	}
+	file.classified = append(file.classified, classifiedLine{text, class})
+}
+
+// The path relative to the root, slash separated
+func relative(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		rel = filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
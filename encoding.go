@@ -0,0 +1,121 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order marks at the start of text naming its encoding
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// The encoding of the content from its byte order mark, empty for UTF-8.
+// UTF-16 is a whole number of pairs of bytes, of a size that is even.
+func textEncoding(head []byte, size int64) string {
+	switch {
+	case size%2 != 0:
+		return ""
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return "UTF-16LE"
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return "UTF-16BE"
+	}
+	return ""
+}
+
+// Open the content of the file as UTF-8 text without a byte order mark
+func (file *File) text() (io.ReadCloser, error) {
+	f, err := file.reader()
+	if err != nil {
+		return nil, err
+	}
+	if file.Encoding == "" {
+		r := bufio.NewReader(f)
+		if bom, _ := r.Peek(len(bomUTF8)); bytes.Equal(bom, bomUTF8) {
+			r.Discard(len(bomUTF8))
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{r, f}, nil
+	}
+
+	// UTF-16 is decoded whole, it is rare enough not to stream
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(decodeUTF16(data, file.Encoding))), nil
+}
+
+// Decode UTF-16 after its byte order mark to UTF-8, an odd byte at the
+// end is dropped
+func decodeUTF16(data []byte, encoding string) []byte {
+	data = data[2:]
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if encoding == "UTF-16LE" {
+			units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+		} else {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// Checks the text written to it is valid UTF-8, a character may be split
+// between writes
+type utf8Checker struct {
+	rest    []byte
+	invalid bool
+}
+
+func (c *utf8Checker) Write(p []byte) (int, error) {
+	if c.invalid {
+		return len(p), nil
+	}
+	data := append(c.rest, p...)
+
+	// Hold back a character cut at the end for the next write
+	start := len(data) - 1
+	for start > 0 && start > len(data)-utf8.UTFMax && !utf8.RuneStart(data[start]) {
+		start--
+	}
+	c.rest = nil
+	if start >= 0 && !utf8.FullRune(data[start:]) {
+		c.rest = append([]byte{}, data[start:]...)
+		data = data[:start]
+	}
+	c.invalid = !utf8.Valid(data)
+	return len(p), nil
+}
+
+// Is all of the text written valid
+func (c *utf8Checker) Valid() bool {
+	return !c.invalid && len(c.rest) == 0
+}
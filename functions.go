@@ -0,0 +1,128 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Definitions of functions and methods starting a line of code, by the
+// language; a heuristic that misses some and is fooled by others
+var functionPatterns = map[string][]*regexp.Regexp{}
+
+// Patterns shared by the languages
+var (
+	// A return type and modifiers before a name and its parameters, the
+	// line not ending the statement before any body, as in C, Java or C#
+	typedDef = regexp.MustCompile(`^((?:[\w*&:<>,\[\]?@]+\s+)+)[*&]*([\w:~]+)\s*\([^;{]*(?:\{.*)?$`)
+
+	// A method of a JavaScript class or object, by its name alone
+	methodDef = regexp.MustCompile(`^(?:(?:static|async|get|set|public|private|protected)\s+)*` +
+		`([\w$]+)\s*\([^)]*\)\s*(?::[^{=]*)?\{$`)
+
+	jsFunction = []string{
+		`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\b`,
+		`^(?:export\s+)?(?:const|let|var)\s+[\w$]+\s*=\s*(?:async\s+)?(?:function\b|(?:\([^)]*\)|[\w$]+)\s*(?::[^=]*)?=>)`,
+	}
+)
+
+// Words that start statements looking like a definition
+var notFunctions = map[string]bool{
+	"if": true, "else": true, "for": true, "foreach": true, "while": true,
+	"switch": true, "case": true, "catch": true, "return": true, "new": true,
+	"delete": true, "throw": true, "sizeof": true, "do": true, "await": true,
+	"yield": true, "typeof": true, "using": true, "lock": true, "fixed": true,
+	"goto": true, "synchronized": true, "function": true, "with": true,
+}
+
+func init() {
+	patterns := map[string][]string{
+		"Go":            {`^func\b`},
+		"Python":        {`^(?:async\s+)?def\s+\w+`},
+		"Starlark":      {`^def\s+\w+`},
+		"Ruby":          {`^def\s+`},
+		"Rust":          {`^(?:pub(?:\([^)]*\))?\s+)?(?:(?:const|async|unsafe|extern(?:\s+"[^"]*")?)\s+)*fn\s+\w+`},
+		"Kotlin":        {`^(?:[a-z]+\s+)*fun\b`},
+		"Scala":         {`^(?:[a-z]+\s+)*def\s+\w+`},
+		"Swift":         {`^(?:@\w+\s+)*(?:[a-z]+\s+)*func\s+`},
+		"PHP":           {`^(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+&?\w+`},
+		"Lua":           {`^(?:local\s+)?function\b`, `=\s*function\b`},
+		"Perl":          {`^sub\s+\w+`},
+		"Shell":         {`^function\s+[\w-]+`, `^[\w-]+\s*\(\)`},
+		"PowerShell":    {`(?i)^function\s+`},
+		"Julia":         {`^function\s+`, `^[\w.]+\([^)]*\)\s*=[^=]`},
+		"R":             {`(?:<-|=)\s*function\b`},
+		"MATLAB":        {`^function\b`},
+		"Nim":           {`^(?:proc|func|method|iterator)\s+`},
+		"Zig":           {`^(?:pub\s+)?(?:export\s+|inline\s+|extern\s+)?fn\s+`},
+		"V":             {`^(?:pub\s+)?fn\s+`},
+		"Odin":          {`^\w+\s*::\s*proc\b`},
+		"TCL":           {`^proc\s+`},
+		"Scheme":        {`^\(define\s+\(`},
+		"Racket":        {`^\(define\s+\(`},
+		"CoffeeScript":  {`^[\w$.@]+\s*[:=]\s*(?:\([^)]*\)\s*)?[-=]>`},
+		"VB":            {`(?i)^(?:(?:public|private|protected|friend|shared|overrides|overridable|mustoverride)\s+)*(?:sub|function)\s+\w+`},
+		"Groovy":        {`^(?:[a-z]+\s+)*def\s+\w+\s*\(`},
+		"Javascript":    jsFunction,
+		"JSX":           jsFunction,
+		"TypeScript":    jsFunction,
+		"TSX":           jsFunction,
+		"Objective-C":   {`^[-+]\s*\(`},
+		"Objective-C++": {`^[-+]\s*\(`},
+	}
+	for name, exprs := range patterns {
+		for _, expr := range exprs {
+			functionPatterns[name] = append(functionPatterns[name], regexp.MustCompile(expr))
+		}
+	}
+}
+
+// Languages whose definitions are a typed name and parameters
+var typedLanguages = map[string]bool{
+	"C": true, "C++": true, "C/C++ Header": true, "C#": true, "Apex": true,
+	"D": true, "Dart": true, "Groovy": true, "Java": true, "Objective-C": true,
+	"Objective-C++": true,
+}
+
+// Languages whose classes hold methods named without a keyword
+var methodLanguages = map[string]bool{
+	"Javascript": true, "JSX": true, "TypeScript": true, "TSX": true,
+}
+
+// Does the line of code start the definition of a function or method
+func (lang Language) defines(line string) bool {
+	for _, pattern := range functionPatterns[lang.Name] {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	if typedLanguages[lang.Name] {
+		if m := typedDef.FindStringSubmatch(line); m != nil && !notFunctions[m[2]] &&
+			!notFunctions[strings.Fields(m[1])[0]] {
+
+			return true
+		}
+	}
+	if methodLanguages[lang.Name] {
+		if m := methodDef.FindStringSubmatch(line); m != nil && !notFunctions[m[1]] {
+			return true
+		}
+	}
+	return false
+}
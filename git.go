@@ -0,0 +1,297 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Run git in the directory of the root and collect the NUL separated
+// file names it lists, keyed by the path they will have while walking
+func gitFiles(root string, args ...string) (map[string]bool, error) {
+	dir := root
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		dir = filepath.Dir(root)
+	}
+
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git %s: %s", args[0],
+				strings.TrimSpace(string(exit.Stderr)))
+		}
+		return nil, err
+	}
+
+	set := map[string]bool{}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			set[filepath.Join(dir, name)] = true
+		}
+	}
+	return set, nil
+}
+
+// Files tracked by git, keyed for use as Scanner.Only
+func GitTracked(root string) (map[string]bool, error) {
+	return gitFiles(root, "ls-files", "-z")
+}
+
+// Files added or modified in the range of revisions, as main...HEAD, that
+// are still in the working tree
+func GitChanged(root string, revisions string) (map[string]bool, error) {
+	return gitFiles(root, "diff", "--name-only", "--relative",
+		"--diff-filter=ACMR", "-z", revisions)
+}
+
+// Split a range of revisions into the older and newer ends, as git diff
+// reads it: A..B are the ends, A...B compares B to where it left A and a
+// single revision is compared to the working tree, an empty newer end
+func splitRange(revisions string) (older string, newer string, base bool) {
+	ends, base := strings.SplitN(revisions, "...", 2), true
+	if len(ends) == 1 {
+		ends, base = strings.SplitN(revisions, "..", 2), false
+	}
+	if len(ends) == 1 {
+		return revisions, "", false
+	}
+	for i := range ends {
+		if ends[i] == "" {
+			ends[i] = "HEAD"
+		}
+	}
+	return ends[0], ends[1], base
+}
+
+// Count the lines added and removed by their classification in the files
+// changed over the range of revisions of the root, as main...HEAD
+func (s *Scanner) GitDiff(root string, revisions string) (*DiffResult, error) {
+	older, newer, base := splitRange(revisions)
+	if base {
+		out, err := exec.Command("git", "-C", root, "merge-base", older, newer).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git merge-base %s %s: %s", older, newer, err)
+		}
+		older = strings.TrimSpace(string(out))
+	}
+	changed, err := gitFiles(root, "diff", "--name-only", "--relative", "-z", revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	oldDir, err := GitExport(root, older)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(oldDir)
+	newDir := root
+	if newer != "" {
+		if newDir, err = GitExport(root, newer); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(newDir)
+	}
+
+	// Only the changed files of both trees are scanned
+	dir := root
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		dir = filepath.Dir(root)
+	}
+	only := s.Only
+	defer func() { s.Only = only }()
+	s.Only = map[string]bool{}
+	for name := range changed {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			continue
+		}
+		s.Only[filepath.Join(oldDir, rel)] = true
+		s.Only[filepath.Join(newDir, rel)] = true
+	}
+	return s.Diff(oldDir, newDir)
+}
+
+// Files added or modified in the working tree relative to HEAD,
+// including untracked files that are not ignored
+func GitDirty(root string) (map[string]bool, error) {
+	set, err := gitFiles(root, "diff", "--name-only", "--relative",
+		"--diff-filter=ACMR", "-z", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitFiles(root, "ls-files", "-z", "--others",
+		"--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for name := range untracked {
+		set[name] = true
+	}
+	return set, nil
+}
+
+// Export the tree of the root directory at a git revision into a new
+// temporary directory, the caller removes it when done
+func GitExport(root string, ref string) (string, error) {
+	prefix, err := exec.Command("git", "-C", root, "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %s", err)
+	}
+	treeish := ref
+	if dir := strings.TrimSuffix(strings.TrimSpace(string(prefix)), "/"); dir != "" {
+		treeish = ref + ":" + dir
+	}
+
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		return "", err
+	}
+	archive := exec.Command("git", "-C", root, "archive", "--format=tar", treeish)
+	out, err := archive.StdoutPipe()
+	if err == nil {
+		err = archive.Start()
+	}
+	if err == nil {
+		err = untar(out, dir)
+		if waitErr := archive.Wait(); err == nil && waitErr != nil {
+			err = fmt.Errorf("git archive %s: %s", ref, waitErr)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// Extract the regular files of a tar stream under the directory
+func untar(r io.Reader, dir string) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, archive)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// The last commit on HEAD made before the time, empty when there is none
+func GitRevision(root string, before time.Time) (string, error) {
+	out, err := exec.Command("git", "-C", root, "rev-list", "-1",
+		"--before="+before.Format(time.RFC3339), "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-list: %s", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Split a remote repository given as url@ref into its url and ref, ok is
+// false when the argument is not the url of a remote repository
+func ParseRemote(arg string) (url string, ref string, ok bool) {
+	path := -1
+	for _, scheme := range []string{"https://", "http://", "ssh://", "git://", "file://"} {
+		if strings.HasPrefix(arg, scheme) {
+			path = len(arg)
+			if slash := strings.Index(arg[len(scheme):], "/"); slash >= 0 {
+				path = len(scheme) + slash
+			}
+		}
+	}
+	// The scp form user@host:path
+	if path < 0 && strings.HasPrefix(arg, "git@") {
+		path = strings.Index(arg, ":")
+	}
+	if path < len("git@") {
+		return "", "", false
+	}
+	if at := strings.Index(arg[path:], "@"); at >= 0 {
+		return arg[:path+at], arg[path+at+1:], true
+	}
+	return arg, "", true
+}
+
+// Fetch the files of a remote repository at a branch, tag or commit, HEAD
+// when empty, into a new temporary directory, the caller removes it when
+// done
+func GitClone(url string, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	// Git would take either as an option, like --upload-pack=cmd
+	if strings.HasPrefix(url, "-") || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("invalid remote %s@%s", url, ref)
+	}
+	dir, err := ioutil.TempDir("", "codecount")
+	if err != nil {
+		return "", err
+	}
+	git := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	err = git("init", "--quiet")
+	// Only the commit is fetched when the server allows it by name,
+	// abbreviated commits need the history to be found in
+	if err == nil && git("fetch", "--quiet", "--depth", "1", url, ref) == nil {
+		err = git("checkout", "--quiet", "FETCH_HEAD")
+	} else if err == nil {
+		err = git("fetch", "--quiet", url,
+			"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*")
+		if err == nil {
+			err = git("checkout", "--quiet", ref)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,72 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"os"
+	"time"
+)
+
+// Snapshot is the counts of the tree at a commit
+type Snapshot struct {
+	Date       time.Time `json:"date"`
+	Commit     string    `json:"commit"`
+	Totals     Count     `json:"totals"`
+	ByLanguage []Group   `json:"byLanguage"`
+}
+
+// Count the tree of the root as it was at each of the dates, using the
+// last commit before the date. Dates before the first commit are left
+// out and a commit seen at an earlier date is not scanned again.
+func (s *Scanner) History(root string, dates []time.Time) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+	for _, date := range dates {
+		commit, err := GitRevision(root, date)
+		if err != nil {
+			return nil, err
+		}
+		if commit == "" {
+			continue
+		}
+
+		last := len(snapshots) - 1
+		if last >= 0 && snapshots[last].Commit == commit {
+			snapshot := snapshots[last]
+			snapshot.Date = date
+			snapshots = append(snapshots, snapshot)
+			continue
+		}
+
+		dir, err := GitExport(root, commit)
+		if err != nil {
+			return nil, err
+		}
+		result, err := s.Scan(dir)
+		os.RemoveAll(dir)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{
+			Date:       date,
+			Commit:     commit,
+			Totals:     result.Totals(),
+			ByLanguage: result.ByLanguage(),
+		})
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,223 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A directory of the HTML report holding its totals and what is inside
+type htmlDir struct {
+	Name  string
+	Open  bool
+	Count Count
+	Dirs  []*htmlDir
+	Files []Group
+}
+
+// A slice of the language chart
+type htmlSlice struct {
+	Name    string
+	Path    string
+	Color   template.CSS
+	Percent float64
+}
+
+// Write a self-contained HTML report of the result with sortable tables, a
+// tree of the directories below root and a chart of code by language
+func (r *Result) WriteHTML(w io.Writer, root string) error {
+	languages := r.ByLanguage()
+	return htmlReport.Execute(w, map[string]interface{}{
+		"Root":       root,
+		"Date":       time.Now().Format("2006-01-02 15:04"),
+		"Totals":     r.Totals(),
+		"ByLanguage": languages,
+		"ByFile":     r.ByFile(),
+		"Tree":       r.tree(root),
+		"Chart":      chart(languages),
+	})
+}
+
+// Arrange the counted files into their directories below root
+func (r *Result) tree(root string) *htmlDir {
+	top := &htmlDir{Name: filepath.Base(root), Open: true}
+	if abs, err := filepath.Abs(root); err == nil {
+		top.Name = filepath.Base(abs)
+	}
+	dirs := map[string]*htmlDir{"": top}
+	for i := 0; i < len(r.Files); i++ {
+		if !r.Files[i].Counted() {
+			continue
+		}
+		path, err := filepath.Rel(root, r.Files[i].Path)
+		if err != nil {
+			path = r.Files[i].Path
+		}
+		count := r.Files[i].count()
+		top.Count.Add(count)
+
+		parts := strings.Split(filepath.ToSlash(path), "/")
+		dir := top
+		for j := 0; j < len(parts)-1; j++ {
+			key := strings.Join(parts[:j+1], "/")
+			if _, found := dirs[key]; !found {
+				dirs[key] = &htmlDir{Name: parts[j]}
+				dir.Dirs = append(dir.Dirs, dirs[key])
+			}
+			dir = dirs[key]
+			dir.Count.Add(count)
+		}
+		dir.Files = append(dir.Files, Group{parts[len(parts)-1], count})
+	}
+	for _, dir := range dirs {
+		sort.Slice(dir.Dirs, func(i, j int) bool { return dir.Dirs[i].Name < dir.Dirs[j].Name })
+		sort.Slice(dir.Files, func(i, j int) bool { return dir.Files[i].Name < dir.Files[j].Name })
+	}
+	return top
+}
+
+// The slices of a pie chart of code lines by language in a circle of
+// radius 1, the largest first
+func chart(languages []Group) []htmlSlice {
+	groups := make([]Group, len(languages))
+	copy(groups, languages)
+	SortGroups(groups, "code", false)
+	total := 0
+	for _, group := range groups {
+		total += group.Code
+	}
+
+	slices := []htmlSlice{}
+	angle := 0.0
+	for i, group := range groups {
+		if group.Code == 0 {
+			continue
+		}
+		share := float64(group.Code) / float64(total)
+		slice := htmlSlice{
+			Name:    group.Name,
+			Color:   template.CSS(fmt.Sprintf("hsl(%d,60%%,55%%)", i*137%360)),
+			Percent: math.Round(share*1000) / 10,
+		}
+		if share >= 1 {
+			slice.Path = "M1 0A1 1 0 1 1 -1 0A1 1 0 1 1 1 0Z"
+		} else {
+			end := angle + share*2*math.Pi
+			large := 0
+			if share > 0.5 {
+				large = 1
+			}
+			slice.Path = fmt.Sprintf("M0 0L%.4f %.4fA1 1 0 %d 1 %.4f %.4fZ",
+				math.Cos(angle), math.Sin(angle), large, math.Cos(end), math.Sin(end))
+			angle = end
+		}
+		slices = append(slices, slice)
+	}
+	return slices
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Codecount - {{.Root}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.2em 0.8em; border-bottom: 1px solid #ddd; }
+th { cursor: pointer; background: #f4f4f4; }
+td.n, th.n { text-align: right; }
+tfoot td { font-weight: bold; }
+details { margin-left: 1.2em; }
+summary { cursor: pointer; }
+.chart { display: flex; align-items: center; gap: 2em; }
+.key span { display: inline-block; width: 0.8em; height: 0.8em; margin-right: 0.4em; }
+</style>
+</head>
+<body>
+<h1>Codecount - {{.Root}}</h1>
+<p>{{.Totals.Files}} files, {{.Totals.Code}} lines of code, {{.Totals.Comments}} comments and {{.Totals.Blanks}} blank lines, counted {{.Date}}</p>
+
+<h2>Languages</h2>
+<div class="chart">
+<svg viewBox="-1.05 -1.05 2.1 2.1" width="240" height="240">
+{{- range .Chart}}
+<path d="{{.Path}}" fill="{{.Color}}"><title>{{.Name}} {{.Percent}}%</title></path>
+{{- end}}
+</svg>
+<div class="key">
+{{- range .Chart}}
+<div><span style="background: {{.Color}}"></span>{{.Name}} {{.Percent}}%</div>
+{{- end}}
+</div>
+</div>
+{{template "table" .ByLanguage}}
+
+<h2>Directories</h2>
+{{template "dir" .Tree}}
+
+<h2>Files</h2>
+{{template "table" .ByFile}}
+
+<script>
+document.querySelectorAll("th").forEach(function(th) {
+	th.addEventListener("click", function() {
+		var table = th.closest("table"), body = table.tBodies[0];
+		var column = th.cellIndex, number = th.classList.contains("n");
+		var order = th.dataset.order == "down" ? 1 : -1;
+		if (!number && !th.dataset.order) order = 1;
+		th.dataset.order = order == 1 ? "up" : "down";
+		Array.from(body.rows).sort(function(a, b) {
+			var x = a.cells[column].textContent, y = b.cells[column].textContent;
+			if (number) return (x - y) * order;
+			return x.localeCompare(y) * order;
+		}).forEach(function(row) { body.appendChild(row); });
+	});
+});
+</script>
+</body>
+</html>
+{{define "table"}}<table>
+<thead><tr><th>Name</th><th class="n">Files</th><th class="n">Blank</th><th class="n">Comment</th><th class="n">Code</th><th class="n">Lines</th></tr></thead>
+<tbody>
+{{- range .}}
+<tr><td>{{.Name}}</td><td class="n">{{.Files}}</td><td class="n">{{.Blanks}}</td><td class="n">{{.Comments}}</td><td class="n">{{.Code}}</td><td class="n">{{.Lines}}</td></tr>
+{{- end}}
+</tbody>
+</table>{{end}}
+{{define "dir"}}<details{{if .Open}} open{{end}}>
+<summary>{{.Name}}/ &mdash; {{.Count.Files}} files, {{.Count.Code}} code, {{.Count.Lines}} lines</summary>
+{{- range .Dirs}}
+{{template "dir" .}}
+{{- end}}
+{{- if .Files}}
+<table><tbody>
+{{- range .Files}}
+<tr><td>{{.Name}}</td><td class="n">{{.Code}} code</td><td class="n">{{.Lines}} lines</td></tr>
+{{- end}}
+</tbody></table>
+{{- end}}
+</details>{{end}}
+`))
@@ -0,0 +1,187 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A pattern from a .gitignore file
+type ignoreRule struct {
+	pattern *regexp.Regexp // Matches the slash separated relative path
+	negate  bool           // Pattern started with ! to re-include
+	dirOnly bool           // Pattern ended with / to match directories
+}
+
+// The rules of a .gitignore file and the directory they apply to
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// The .gitignore files in effect while walking, outermost first
+type ignoreStack []*ignoreFile
+
+// Parse a line of a .gitignore file, false for blanks and comments
+func parseIgnore(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the
+	// directory of the .gitignore, otherwise it matches at any depth
+	expr := "^(.*/)?"
+	if strings.Contains(line, "/") {
+		expr = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			expr += "(.*/)?"
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			expr += ".*"
+			i++
+		case line[i] == '*':
+			expr += "[^/]*"
+		case line[i] == '?':
+			expr += "[^/]"
+		case line[i] == '[' && strings.Contains(line[i:], "]"):
+			end := i + strings.Index(line[i:], "]")
+			class := line[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr += "[" + strings.Replace(class, "\\", "\\\\", -1) + "]"
+			i = end
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			expr += regexp.QuoteMeta(line[i : i+1])
+		default:
+			expr += regexp.QuoteMeta(line[i : i+1])
+		}
+	}
+
+	pattern, err := regexp.Compile(expr + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// Read the .gitignore in the directory, nil when there is none
+func readIgnore(dir string) (*ignoreFile, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ignore := &ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnore(scanner.Text()); ok {
+			ignore.rules = append(ignore.rules, rule)
+		}
+	}
+	return ignore, scanner.Err()
+}
+
+// Drop the .gitignore files of directories the path is not within
+func (stack ignoreStack) enter(path string) ignoreStack {
+	for len(stack) > 0 {
+		rel, err := filepath.Rel(stack[len(stack)-1].dir, path)
+		if err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			break
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return stack
+}
+
+// Is the path ignored, the last matching rule of the innermost
+// .gitignore decides
+func (stack ignoreStack) ignored(path string, dir bool) bool {
+	ignored := false
+	for _, ignore := range stack {
+		rel, err := filepath.Rel(ignore.dir, path)
+		if err != nil || rel == "." {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range ignore.rules {
+			if rule.dirOnly && !dir {
+				continue
+			}
+			if rule.pattern.MatchString(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Compile glob patterns, written as in a .gitignore, that are matched
+// against paths relative to the scan root
+func compileGlobs(patterns []string) []ignoreRule {
+	rules := []ignoreRule{}
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnore(pattern); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Does any of the patterns match the relative path
+func matchGlobs(rules []ignoreRule, rel string, dir bool) bool {
+	for _, rule := range rules {
+		if rule.dirOnly && !dir {
+			continue
+		}
+		if rule.pattern.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
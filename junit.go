@@ -0,0 +1,122 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Outcome is a limit checked on a result, Failure telling how it failed
+type Outcome struct {
+	Suite   string // The kind of limit, the checks or the lines of each file
+	Name    string
+	Failure string // Empty when the limit was met
+}
+
+// Check the limits on the totals and the lines of each counted file, no
+// limit on the lines when maxLines is 0
+func (r *Result) CheckLimits(checks []Check, maxLines int) []Outcome {
+	outcomes := []Outcome{}
+	totals := r.Totals()
+	for _, check := range checks {
+		outcome := Outcome{Suite: "limits", Name: check.String()}
+		if check.Holds(totals) {
+			value, _ := totals.Column(check.Column)
+			outcome.Failure = fmt.Sprintf("%s, %s is %d", check, check.Column, value)
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	if maxLines > 0 {
+		for _, file := range r.Files {
+			if !file.Counted() {
+				continue
+			}
+			outcome := Outcome{Suite: "max-file-lines", Name: file.Path}
+			if file.Lines > maxLines {
+				outcome.Failure = fmt.Sprintf("%s has %d lines, more than %d",
+					file.Path, file.Lines, maxLines)
+			}
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Write the outcomes as JUnit XML, a test suite for each kind of limit and
+// a failure for each limit not met, for CI systems to show with their tests
+func WriteJUnit(w io.Writer, outcomes []Outcome) error {
+	report := junitSuites{Name: "codecount"}
+	index := map[string]int{}
+	for _, outcome := range outcomes {
+		i, found := index[outcome.Suite]
+		if !found {
+			i = len(report.Suites)
+			index[outcome.Suite] = i
+			report.Suites = append(report.Suites, junitSuite{Name: outcome.Suite})
+		}
+		suite := &report.Suites[i]
+		test := junitCase{ClassName: "codecount." + outcome.Suite, Name: outcome.Name}
+		if outcome.Failure != "" {
+			test.Failure = &junitFailure{outcome.Failure, "Limit failed: " + outcome.Failure}
+			suite.Failures++
+			report.Failures++
+		}
+		suite.Cases = append(suite.Cases, test)
+		suite.Tests++
+		report.Tests++
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,811 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Language describes how the lines of a file are classified
+type Language struct {
+	Name       string   // Print name
+	Extension  []string // File Extensions
+	Filename   []string // Exact file names
+	OpenBlock  string   // Block comment opening
+	CloseBlock string   // Block comment closing
+	Comment    []string // Line comment markers
+	ColComment string   // Characters marking a comment in the comment column
+	ColNumber  int      // Comment column counted from 1, the first when 0
+	Nested     bool     // Block comments nest
+	NestOpen   string   // Second block comment opening, always nesting
+	NestClose  string   // Second block comment closing
+	EndMark    string   // End of code marker
+	Quotes     []string // String delimiters with backslash escapes
+	RawQuotes  []string // String delimiters without escapes, may span lines
+	DocString  []string // String delimiters that are comments starting a line
+	BlockWrap  []string // Open and close around a block comment, not code
+	LongPrefix string   // Prefix of a long bracket block comment, like --[==[
+	HereDoc    bool     // Here documents, like <<EOF, are code
+	Literate   bool     // Only lines after > or between \begin{code} are source
+	Sections   bool     // Made of template, script and style sections
+}
+type Languages []Language
+
+// String delimiters shared by the languages
+var (
+	doubleQuote = []string{"\""}
+	singleQuote = []string{"'"}
+	bothQuotes  = []string{"\"", "'"}
+	backQuote   = []string{"`"}
+
+	tripleQuotes = []string{`"""`, "'''"}
+
+	// Braces of a JSX expression holding only a comment
+	jsxWrap = []string{"{", "}"}
+)
+
+var languages = Languages{
+	Language{Name: "ABAP", Extension: []string{".abap"},
+		Comment: []string{"\""}, ColComment: "*"},
+	Language{Name: "Ansible", Comment: []string{"#"}},
+	Language{Name: "Apex", Extension: []string{".cls", ".trigger"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: singleQuote},
+	Language{Name: "Assembly", Extension: []string{".s", ".asm"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{";", "#", "//", "@"}},
+	Language{Name: "Batch", Extension: []string{".bat"}, Comment: []string{"REM"}},
+	Language{Name: "C", Extension: []string{".c"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "C++", Extension: []string{".cpp"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "C/C++ Header", Extension: []string{".h"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "CMake", Extension: []string{".cmake"},
+		Filename:  []string{"CMakeLists.txt"},
+		OpenBlock: "#[[", CloseBlock: "]]", Comment: []string{"#"}},
+	Language{Name: "COBOL", Extension: []string{".cbl", ".cob", ".cpy"},
+		Comment: []string{"*>"}, ColComment: "*/", ColNumber: 7,
+		Quotes: bothQuotes},
+	Language{Name: "CoffeeScript", Extension: []string{".coffee"},
+		OpenBlock: "###", CloseBlock: "###", Comment: []string{"#"},
+		Quotes: bothQuotes},
+	Language{Name: "CSS", Extension: []string{".css"},
+		OpenBlock: "/*", CloseBlock: "*/",
+		Quotes: bothQuotes},
+	Language{Name: "C#", Extension: []string{".cs"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "D", Extension: []string{".d"},
+		OpenBlock: "/*", CloseBlock: "*/", NestOpen: "/+", NestClose: "+/",
+		Comment: []string{"//"},
+		Quotes:  bothQuotes, RawQuotes: backQuote},
+	Language{Name: "Dart", Extension: []string{".dart"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: tripleQuotes},
+	Language{Name: "Dockerfile", Extension: []string{".dockerfile"},
+		Filename: []string{"Dockerfile"}, Comment: []string{"#"}},
+	Language{Name: "Elm", Extension: []string{".elm"},
+		OpenBlock: "{-", CloseBlock: "-}", Nested: true, Comment: []string{"--"}},
+	Language{Name: "Fortran", Extension: []string{".f90", ".f95", ".f03", ".f08"},
+		Comment: []string{"!"}},
+	Language{Name: "Fortran 77", Extension: []string{".f", ".for", ".f77"},
+		Comment: []string{"!"}, ColComment: "Cc*!"},
+	Language{Name: "Gherkin", Extension: []string{".feature"}, Comment: []string{"#"}},
+	Language{Name: "Go", Extension: []string{".go"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: backQuote},
+	Language{Name: "GraphQL", Extension: []string{".graphql", ".gql"},
+		Comment: []string{"#"}, DocString: []string{`"""`},
+		Quotes: doubleQuote, RawQuotes: []string{`"""`}},
+	Language{Name: "Groovy", Extension: []string{".groovy", ".gradle"},
+		Filename:  []string{"Jenkinsfile"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "Haskell", Extension: []string{".hs"},
+		OpenBlock: "{-", CloseBlock: "-}", Nested: true, Comment: []string{"--"},
+		Quotes: doubleQuote},
+	Language{Name: "HTML", Extension: []string{".html", ".htm"},
+		OpenBlock: "<!--", CloseBlock: "-->"},
+	Language{Name: "INI", Extension: []string{".ini", ".cfg", ".conf"},
+		Comment: []string{";", "#"}},
+	Language{Name: "Java", Extension: []string{".java"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "Javascript", Extension: []string{".js"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "JSON", Extension: []string{".json"}},
+	Language{Name: "JSX", Extension: []string{".jsx"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: backQuote, BlockWrap: jsxWrap},
+	Language{Name: "Julia", Extension: []string{".jl"},
+		OpenBlock: "#=", CloseBlock: "=#", Nested: true, Comment: []string{"#"},
+		Quotes: doubleQuote, RawQuotes: []string{`"""`}},
+	Language{Name: "Jupyter Notebook", Extension: []string{".ipynb"}},
+	Language{Name: "Kotlin", Extension: []string{".kt", ".kts"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: tripleQuotes},
+	Language{Name: "Literate Haskell", Extension: []string{".lhs"},
+		OpenBlock: "{-", CloseBlock: "-}", Nested: true, Comment: []string{"--"},
+		Quotes: doubleQuote, Literate: true},
+	Language{Name: "Lua", Extension: []string{".lua"},
+		Comment: []string{"--"}, LongPrefix: "--",
+		Quotes: bothQuotes},
+	Language{Name: "Makefile", Extension: []string{".mk", ".mak"},
+		Filename: []string{"Makefile", "makefile", "GNUmakefile"},
+		Comment:  []string{"#"}},
+	Language{Name: "Markdown", Extension: []string{".md"}},
+	Language{Name: "MATLAB", Extension: []string{".m"},
+		OpenBlock: "%{", CloseBlock: "%}", Comment: []string{"%"},
+		Quotes: doubleQuote},
+	Language{Name: "MySQL",
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"--", "#"},
+		RawQuotes: []string{"'", "`"}},
+	Language{Name: "Nim", Extension: []string{".nim", ".nims"},
+		OpenBlock: "#[", CloseBlock: "]#", Nested: true, Comment: []string{"#"},
+		Quotes: doubleQuote, RawQuotes: []string{`"""`}},
+	Language{Name: "Nix", Extension: []string{".nix"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"#"},
+		Quotes: doubleQuote},
+	Language{Name: "Objective-C",
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "Objective-C++", Extension: []string{".mm"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "Odin", Extension: []string{".odin"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: backQuote},
+	Language{Name: "Perl", Extension: []string{".pl"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"}, EndMark: "__END__",
+		Quotes: bothQuotes, HereDoc: true},
+	Language{Name: "PHP", Extension: []string{".php"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//", "#"},
+		EndMark: "__halt_compiler()",
+		Quotes:  bothQuotes},
+	Language{Name: "PowerShell", Extension: []string{".ps1", ".psm1", ".psd1"},
+		OpenBlock: "<#", CloseBlock: "#>", Comment: []string{"#"},
+		RawQuotes: bothQuotes},
+	Language{Name: "Preprocessed Assembly", Extension: []string{".S"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{";", "//", "@"}},
+	Language{Name: "Prolog", Extension: []string{".prolog"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"%"},
+		Quotes: bothQuotes},
+	Language{Name: "Protocol Buffers", Extension: []string{".proto"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes},
+	Language{Name: "Puppet", Extension: []string{".pp"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"#"},
+		Quotes: bothQuotes},
+	Language{Name: "PureScript", Extension: []string{".purs"},
+		OpenBlock: "{-", CloseBlock: "-}", Nested: true, Comment: []string{"--"}},
+	Language{Name: "Python", Extension: []string{".py", ".pyw"},
+		Comment: []string{"#"},
+		Quotes:  bothQuotes, RawQuotes: tripleQuotes, DocString: tripleQuotes},
+	Language{Name: "R", Extension: []string{".r"}, Comment: []string{"#"},
+		Quotes: bothQuotes},
+	Language{Name: "Racket", Extension: []string{".rkt"},
+		OpenBlock: "#|", CloseBlock: "|#", Nested: true, Comment: []string{";", "#;"}},
+	Language{Name: "Reason", Extension: []string{".re"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"}},
+	Language{Name: "ReScript", Extension: []string{".res"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"}},
+	Language{Name: "RestructuredText", Extension: []string{".rst"}},
+	Language{Name: "RPGLE", Extension: []string{".rpgle", ".sqlrpgle"},
+		Comment: []string{"//"}, ColComment: "*", ColNumber: 7,
+		Quotes: singleQuote},
+	Language{Name: "Ruby", Extension: []string{".rb"},
+		Filename:  []string{"Rakefile", "Gemfile"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"#"}, EndMark: "__END__",
+		Quotes: bothQuotes, HereDoc: true},
+	Language{Name: "Rust", Extension: []string{".rs"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: doubleQuote},
+	Language{Name: "SAS", Extension: []string{".sas"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"*"}},
+	Language{Name: "Scala", Extension: []string{".scala", ".sc"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: tripleQuotes},
+	Language{Name: "Scheme", Extension: []string{".scm", ".ss"},
+		OpenBlock: "#|", CloseBlock: "|#", Nested: true, Comment: []string{";", "#;"}},
+	Language{Name: "Shell", Extension: []string{".sh", ".bash", ".zsh", ".ksh"},
+		Filename: []string{".bashrc", ".bash_profile", ".zshrc", ".profile"},
+		Comment:  []string{"#"}, HereDoc: true,
+		Quotes: doubleQuote, RawQuotes: singleQuote},
+	Language{Name: "Smalltalk", Extension: []string{".st"},
+		OpenBlock: "\"", CloseBlock: "\"",
+		RawQuotes: singleQuote},
+	Language{Name: "SPSS", Extension: []string{".sps"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"*", "COMMENT"}},
+	Language{Name: "SQL", Extension: []string{".sql"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"--"},
+		RawQuotes: singleQuote},
+	Language{Name: "Starlark", Extension: []string{".bzl"},
+		Filename: []string{"BUILD", "BUILD.bazel", "WORKSPACE", "WORKSPACE.bazel"},
+		Comment:  []string{"#"},
+		Quotes:   bothQuotes},
+	Language{Name: "Stata", Extension: []string{".do", ".ado"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//", "*"},
+		Quotes: doubleQuote},
+	Language{Name: "Svelte", Extension: []string{".svelte"},
+		OpenBlock: "<!--", CloseBlock: "-->", Sections: true},
+	Language{Name: "Swift", Extension: []string{".swift"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: doubleQuote},
+	Language{Name: "TCL", Extension: []string{".tcl"}, Comment: []string{"#"},
+		Quotes: doubleQuote},
+	Language{Name: "Terraform", Extension: []string{".tf", ".tfvars", ".hcl"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"#", "//"},
+		Quotes: doubleQuote, HereDoc: true},
+	Language{Name: "Text", Extension: []string{".txt"}},
+	Language{Name: "Thrift", Extension: []string{".thrift"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//", "#"},
+		Quotes: bothQuotes},
+	Language{Name: "TOML", Extension: []string{".toml"}, Comment: []string{"#"},
+		Quotes: doubleQuote, RawQuotes: singleQuote},
+	Language{Name: "TSX", Extension: []string{".tsx"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: backQuote, BlockWrap: jsxWrap},
+	Language{Name: "TypeScript", Extension: []string{".ts", ".mts", ".cts"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: backQuote},
+	Language{Name: "V", Extension: []string{".v"},
+		OpenBlock: "/*", CloseBlock: "*/", Nested: true, Comment: []string{"//"},
+		Quotes: bothQuotes, RawQuotes: backQuote},
+	Language{Name: "VB", Extension: []string{".vb", ".mac", ".frm", ".frx", ".bas"},
+		OpenBlock: "/*", CloseBlock: "*/", Comment: []string{"'"}},
+	Language{Name: "Visualforce", Extension: []string{".page", ".component"},
+		OpenBlock: "<!--", CloseBlock: "-->"},
+	Language{Name: "Vue", Extension: []string{".vue"},
+		OpenBlock: "<!--", CloseBlock: "-->", Sections: true},
+	Language{Name: "XML", Extension: []string{".xml", ".xss", ".xsc", ".xsd", ".xsx"},
+		OpenBlock: "<!--", CloseBlock: "-->"},
+	Language{Name: "YAML", Extension: []string{".yaml", ".yml"}, Comment: []string{"#"}},
+	Language{Name: "Zig", Extension: []string{".zig"}, Comment: []string{"//"},
+		Quotes: bothQuotes},
+}
+
+// Does the line start with one of the line comment markers
+func (lang Language) isComment(line string) bool {
+	for _, marker := range lang.Comment {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Is the untrimmed line marked as a comment in the comment column
+func (lang Language) isColComment(line string) bool {
+	col := lang.ColNumber
+	if col == 0 {
+		col = 1
+	}
+	return lang.ColComment != "" && len(line) >= col &&
+		strings.IndexByte(lang.ColComment, line[col-1]) > -1
+}
+
+// Does the line hold a line comment marker after some code
+func (lang Language) hasComment(line string) bool {
+	for _, marker := range lang.Comment {
+		if strings.Index(line, marker) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Blank out the text of the string literals in the line, leaving the
+// quotes, so comment markers inside them are not seen.  A raw string
+// left open at the end of the line is returned to carry on the next.
+func (lang Language) stripStrings(line string) (string, string) {
+	if len(lang.Quotes) == 0 && len(lang.RawQuotes) == 0 {
+		return line, ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		// Comments are kept as they are, up to the end of a block
+		if lang.isComment(line[i:]) {
+			b.WriteString(line[i:])
+			break
+		}
+		if opener, closer := lang.blockAt(line[i:]); opener != "" {
+			end := strings.Index(line[i+len(opener):], closer)
+			if end == -1 {
+				b.WriteString(line[i:])
+				break
+			}
+			end += i + len(opener) + len(closer)
+			b.WriteString(line[i:end])
+			i = end
+			continue
+		}
+
+		quote, raw := lang.quoteAt(line[i:])
+		if quote == "" {
+			b.WriteByte(line[i])
+			i++
+			continue
+		}
+		b.WriteString(quote)
+		i += len(quote)
+		closed := false
+		for i < len(line) {
+			if !raw && line[i] == '\\' {
+				i += 2
+			} else if strings.HasPrefix(line[i:], quote) {
+				b.WriteString(quote)
+				i += len(quote)
+				closed = true
+				break
+			} else {
+				i++
+			}
+		}
+		if !closed && raw {
+			return b.String(), quote
+		}
+	}
+	return b.String(), ""
+}
+
+// Long brackets with any number of = between, like [==[
+var longBrackets = regexp.MustCompile(`\[(=*)\[`)
+
+// The first long bracket block comment opening in the line and the
+// close with the same level
+func (lang Language) longBracket(line string) (string, string) {
+	if lang.LongPrefix == "" {
+		return "", ""
+	}
+	for _, m := range longBrackets.FindAllStringSubmatchIndex(line, -1) {
+		if strings.HasSuffix(line[:m[0]], lang.LongPrefix) {
+			return lang.LongPrefix + line[m[0]:m[1]],
+				"]" + line[m[2]:m[3]] + "]"
+		}
+	}
+	return "", ""
+}
+
+// Does the line start a documentation comment, like /// or /** of the C
+// family or a docstring
+func (lang Language) startsDoc(line string) bool {
+	if lang.docStringAt(line) != "" {
+		return true
+	}
+	for _, marker := range lang.Comment {
+		if marker == "//" && (strings.HasPrefix(line, "//!") ||
+			strings.HasPrefix(line, "///") && !strings.HasPrefix(line, "////")) {
+
+			return true
+		}
+	}
+	return lang.OpenBlock == "/*" && (strings.HasPrefix(line, "/*!") ||
+		strings.HasPrefix(line, "/**") && !strings.HasPrefix(line, "/**/"))
+}
+
+// A Go declaration, documented by the comment lines right before it
+var goDeclaration = regexp.MustCompile(`^(package|func|type|var|const)\b`)
+
+// Does the line start with a block comment opening
+func (lang Language) startsBlock(line string) bool {
+	if lang.OpenBlock != "" && strings.HasPrefix(line, lang.OpenBlock) {
+		return true
+	}
+	open, _ := lang.longBracket(line)
+	return open != "" && strings.HasPrefix(line, open)
+}
+
+// The opening of a Markdown fenced code block and its language
+var markdownFence = regexp.MustCompile("^(```+|~~~+)\\s*([\\w+#-]*)")
+
+// A here document operator and its terminator, not a <<< here string
+var hereDocs = regexp.MustCompile(
+	`^<<[-~]?\s*(?:'(\w+)'|"(\w+)"|([A-Za-z_]\w*))`)
+
+// Where << is also an operator the terminator follows it directly, and
+// a bare one is upper case, so class << self and items << item are not
+// here documents
+var tightHereDocs = map[string]*regexp.Regexp{
+	"Perl": regexp.MustCompile(`^<<[-~]?(?:'(\w+)'|"(\w+)"|([A-Z_][A-Z0-9_]*))`),
+	"Ruby": regexp.MustCompile(`^<<[-~]?(?:'(\w+)'|"(\w+)"|([A-Z_][A-Z0-9_]*))`),
+}
+
+// The terminator of a here document opened in the code of the line,
+// outside its string literals and comments
+func (lang Language) hereDoc(line string) string {
+	if !lang.HereDoc {
+		return ""
+	}
+	pattern := hereDocs
+	if tight, found := tightHereDocs[lang.Name]; found {
+		pattern = tight
+	}
+	for i := 0; i < len(line); {
+		if lang.isComment(line[i:]) {
+			break
+		}
+		if quote, raw := lang.quoteAt(line[i:]); quote != "" {
+			end := i + len(quote)
+			for end < len(line) && !strings.HasPrefix(line[end:], quote) {
+				if !raw && line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			i = end + len(quote)
+			continue
+		}
+		if strings.HasPrefix(line[i:], "<<") && (i == 0 || line[i-1] != '<') {
+			if m := pattern.FindStringSubmatch(line[i:]); m != nil {
+				return m[1] + m[2] + m[3]
+			}
+		}
+		i++
+	}
+	return ""
+}
+
+// Remove the wrapping around a block comment opening or closing the
+// line, like the braces of {/* comment */} in JSX
+func (lang Language) unwrap(line string) string {
+	if len(lang.BlockWrap) != 2 || lang.OpenBlock == "" {
+		return line
+	}
+	if strings.HasPrefix(line, lang.BlockWrap[0]+lang.OpenBlock) {
+		line = line[len(lang.BlockWrap[0]):]
+	}
+	if strings.HasSuffix(line, lang.CloseBlock+lang.BlockWrap[1]) {
+		line = line[:len(line)-len(lang.BlockWrap[1])]
+	}
+	return line
+}
+
+// The block comment pair opening at the start of the text
+func (lang Language) blockAt(text string) (string, string) {
+	if lang.OpenBlock != "" && strings.HasPrefix(text, lang.OpenBlock) {
+		return lang.OpenBlock, lang.CloseBlock
+	}
+	if lang.NestOpen != "" && strings.HasPrefix(text, lang.NestOpen) {
+		return lang.NestOpen, lang.NestClose
+	}
+	return "", ""
+}
+
+// The longest string delimiter at the start of the text and if it is raw
+func (lang Language) quoteAt(text string) (string, bool) {
+	found, raw := "", false
+	for _, quote := range lang.Quotes {
+		if strings.HasPrefix(text, quote) && len(quote) > len(found) {
+			found, raw = quote, false
+		}
+	}
+	for _, quote := range lang.RawQuotes {
+		if strings.HasPrefix(text, quote) && len(quote) > len(found) {
+			found, raw = quote, true
+		}
+	}
+	return found, raw
+}
+
+// Does the line carry on into the next, as the arguments of a call, the
+// items of a list or the value of an assignment
+func continuesLine(line string) bool {
+	return line != "" && strings.IndexByte("([{,=+%\\", line[len(line)-1]) > -1
+}
+
+// The docstring delimiter starting the line
+func (lang Language) docStringAt(line string) string {
+	for _, quote := range lang.DocString {
+		if strings.HasPrefix(line, quote) {
+			return quote
+		}
+	}
+	return ""
+}
+
+// Find a language by a short tag, like the info string of a Markdown
+// fence or a notebook kernel, trying extensions, names and interpreters
+func languageTagged(tag string) Language {
+	tag = strings.ToLower(tag)
+	if lang, found := extensions["."+tag]; found && tag != "" {
+		return lang
+	}
+	for _, lang := range languages {
+		if strings.ToLower(lang.Name) == tag {
+			return lang
+		}
+	}
+	return languageNamed(interpreters[tag])
+}
+
+// Find a language by its name in any case, an extension or an interpreter
+func FindLanguage(name string) (Language, bool) {
+	lang := languageTagged(name)
+	return lang, lang.Name != ""
+}
+
+// Find a language by its print name
+func languageNamed(name string) Language {
+	for _, lang := range languages {
+		if lang.Name == name {
+			return lang
+		}
+	}
+	return Language{}
+}
+
+// Setup the set of extension types to scan
+var extensions = extensionSet()
+
+func extensionSet() map[string]Language {
+	ext_set := map[string]Language{}
+	for _, lang := range languages {
+		for _, ext := range lang.Extension {
+			ext_set[ext] = lang
+		}
+	}
+	return ext_set
+}
+
+// Setup the set of file names to scan regardless of extension
+var filenames = filenameSet()
+
+func filenameSet() map[string]Language {
+	name_set := map[string]Language{}
+	for _, lang := range languages {
+		for _, name := range lang.Filename {
+			name_set[name] = lang
+		}
+	}
+	return name_set
+}
+
+// Add languages to the table, replacing any with the same name. The
+// added languages take their extensions and file names from others.
+func AddLanguages(langs Languages) {
+	for _, lang := range langs {
+		for i := 0; i < len(languages); i++ {
+			if languages[i].Name == lang.Name {
+				languages = append(languages[:i], languages[i+1:]...)
+				break
+			}
+		}
+		languages = append(languages, lang)
+	}
+	extensions = extensionSet()
+	filenames = filenameSet()
+	languagesHash = hashLanguages()
+}
+
+// Read language definitions from a JSON file holding a list of
+// objects with name, extensions, filenames, comments, colcomment,
+// openblock, closeblock, nested, nestopen, nestclose and endmark
+func ReadLanguages(path string) (Languages, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defs := []struct {
+		Name       string   `json:"name"`
+		Extensions []string `json:"extensions"`
+		Filenames  []string `json:"filenames"`
+		OpenBlock  string   `json:"openblock"`
+		CloseBlock string   `json:"closeblock"`
+		Comments   []string `json:"comments"`
+		ColComment string   `json:"colcomment"`
+		ColNumber  int      `json:"colnumber"`
+		Nested     bool     `json:"nested"`
+		NestOpen   string   `json:"nestopen"`
+		NestClose  string   `json:"nestclose"`
+		EndMark    string   `json:"endmark"`
+		Quotes     []string `json:"quotes"`
+		RawQuotes  []string `json:"rawquotes"`
+		DocStrings []string `json:"docstrings"`
+		BlockWrap  []string `json:"blockwrap"`
+		LongPrefix string   `json:"longprefix"`
+		HereDoc    bool     `json:"heredoc"`
+		Literate   bool     `json:"literate"`
+		Sections   bool     `json:"sections"`
+	}{}
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	langs := Languages{}
+	for i, def := range defs {
+		if def.Name == "" {
+			return nil, fmt.Errorf("%s: language %d has no name", path, i+1)
+		}
+		lang := Language{
+			Name:       def.Name,
+			Filename:   def.Filenames,
+			OpenBlock:  def.OpenBlock,
+			CloseBlock: def.CloseBlock,
+			Comment:    def.Comments,
+			ColComment: def.ColComment,
+			ColNumber:  def.ColNumber,
+			Nested:     def.Nested,
+			NestOpen:   def.NestOpen,
+			NestClose:  def.NestClose,
+			EndMark:    def.EndMark,
+			Quotes:     def.Quotes,
+			RawQuotes:  def.RawQuotes,
+			DocString:  def.DocStrings,
+			BlockWrap:  def.BlockWrap,
+			LongPrefix: def.LongPrefix,
+			HereDoc:    def.HereDoc,
+			Literate:   def.Literate,
+			Sections:   def.Sections,
+		}
+		for _, ext := range def.Extensions {
+			ext = strings.ToLower(ext)
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			lang.Extension = append(lang.Extension, ext)
+		}
+		langs = append(langs, lang)
+	}
+	return langs, nil
+}
+
+// Detect the language of a file by its name, then by its extension,
+// where an extension differing only by case, like .S, is tried first
+func Detect(path string) (Language, bool) {
+	if lang, found := filenames[filepath.Base(path)]; found {
+		return lang, true
+	}
+	if lang, found := extensions[filepath.Ext(path)]; found {
+		return lang, true
+	}
+	lang, found := extensions[strings.ToLower(filepath.Ext(path))]
+	return lang, found
+}
+
+// Interpreters named on a #! line and their languages
+var interpreters = map[string]string{
+	"sh":     "Shell",
+	"bash":   "Shell",
+	"dash":   "Shell",
+	"ksh":    "Shell",
+	"zsh":    "Shell",
+	"python": "Python",
+	"perl":   "Perl",
+	"ruby":   "Ruby",
+	"node":   "Javascript",
+	"nodejs": "Javascript",
+}
+
+// Detect the language of a script from its #! line, the interpreter
+// may be run through env and carry a version like python3.11
+func detectShebang(line string) (Language, bool) {
+	if !strings.HasPrefix(line, "#!") {
+		return Language{}, false
+	}
+	fields := strings.Fields(line[2:])
+	for i := 0; i < len(fields); i++ {
+		name := filepath.Base(fields[i])
+		if name == "env" || strings.HasPrefix(name, "-") {
+			continue
+		}
+		name = strings.TrimRight(name, "0123456789.")
+		if lang, found := interpreters[name]; found {
+			return languageNamed(lang), true
+		}
+		break
+	}
+	return Language{}, false
+}
+
+// Detect the language of a file by its name, falling back to the #!
+// line of files without an extension
+func detectFile(file *File) (Language, bool) {
+	if lang, found := Detect(file.Path); found || filepath.Ext(file.Path) != "" {
+		return lang, found
+	}
+	f, err := file.reader()
+	if err != nil {
+		return Language{}, false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(io.LimitReader(f, 256)).ReadString('\n')
+	return detectShebang(strings.TrimSpace(line))
+}
+
+// A language sharing an extension and the content marking it
+type sniff struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Content marking Objective-C, shared by the extensions it collides on
+var objectiveC = regexp.MustCompile(
+	`(?m)^\s*(#import|@interface|@implementation|@protocol|@end)\b`)
+
+// Extensions shared by languages, resolved to the first language with
+// a pattern matching the start of the file, otherwise the one detected
+var ambiguous = map[string][]sniff{
+	".h": []sniff{
+		{"Objective-C", objectiveC},
+		{"C++", regexp.MustCompile(
+			`(?m)^\s*(class\s+\w+\s*[:{]|namespace\b|template\s*<|using\s+namespace\b|(public|private|protected):)`)},
+	},
+	".m": []sniff{
+		{"Objective-C", regexp.MustCompile(
+			`(?m)^\s*(#import|#include|@interface|@implementation|@protocol)\b`)},
+	},
+	".sql": []sniff{
+		{"MySQL", regexp.MustCompile(
+			`(?im)(\bENGINE\s*=|\bAUTO_INCREMENT\b|^\s*DELIMITER\s|^\s*#)`)},
+	},
+	".pl": []sniff{
+		{"Prolog", regexp.MustCompile(`(?m)^(:-\s*\w|[a-z]\w*(\(.*\))?\s*:-)`)},
+	},
+}
+
+// Resolve the language of a file with an extension shared by languages
+// from the start of its content
+func sniffLanguage(file *File, lang Language) Language {
+	sniffs, found := ambiguous[strings.ToLower(filepath.Ext(file.Path))]
+	if !found {
+		return lang
+	}
+	f, err := file.reader()
+	if err != nil {
+		return lang
+	}
+	defer f.Close()
+	head, _ := ioutil.ReadAll(io.LimitReader(f, 4096))
+	for _, sn := range sniffs {
+		if sn.pattern.Match(head) {
+			if named := languageNamed(sn.name); named.Name != "" {
+				return named
+			}
+		}
+	}
+	return lang
+}
+
+// Host languages and their multi-line string delimiters
+// checked for embedded SQL
+var sqlDelims = map[string][]string{
+	"Go":     []string{"`"},
+	"Java":   []string{`"""`},
+	"Python": []string{`"""`, "'''"},
+}
+
+// A string literal starting with one of these is SQL
+var sqlStart = regexp.MustCompile(
+	`(?i)^(SELECT|INSERT|UPDATE|DELETE|CREATE|ALTER|DROP|WITH|MERGE|TRUNCATE)\b`)
+
+// YAML is Ansible when under a playbooks or roles directory, or when
+// it has the keys of a play at the top level, on the untrimmed line of
+// the play or under the first key of its entry
+var ansiblePath = regexp.MustCompile(`(^|/)(playbooks|roles)/`)
+var ansibleKey = regexp.MustCompile(`^(- |  )?(hosts|tasks):`)
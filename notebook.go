@@ -0,0 +1,116 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// A notebook as saved by Jupyter, only the parts needed to count it
+type notebook struct {
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"`
+	} `json:"cells"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+// Count a Jupyter notebook, the code cells are classified by the
+// language of the kernel and the lines of the other cells are comments.
+// A notebook that does not parse is counted as the JSON it is.
+func (s *Scanner) scanNotebook(file *File) error {
+	data, err := file.read()
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(data) {
+		s.skipInvalid(file)
+		return nil
+	}
+	hash := sha1.Sum(data)
+	file.Hash = hex.EncodeToString(hash[:])
+	file.Scanned = true
+
+	nb := notebook{}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		file.Lang = languageNamed("JSON")
+		return s.scanLines(file, bytes.NewReader(data))
+	}
+
+	lang := languageTagged(nb.Metadata.Kernelspec.Language)
+	if lang.Name == "" {
+		lang = languageTagged(nb.Metadata.LanguageInfo.Name)
+	}
+	if lang.Name == "" {
+		lang = languageNamed("Python")
+	}
+
+	for _, cell := range nb.Cells {
+		source := cellSource(cell.Source)
+		if cell.CellType == "code" {
+			code := File{Path: file.Path, Lang: lang}
+			if err := s.scanLines(&code, strings.NewReader(source)); err != nil {
+				return err
+			}
+			file.merge(&code)
+			continue
+		}
+
+		lines := newLineScanner(strings.NewReader(source))
+		for lines.Scan() {
+			text := strings.TrimSpace(lines.Text())
+			class := commentLine
+			file.Lines++
+			if text == "" {
+				class = blankLine
+				file.Blanks++
+				s.debug("BLNK", file.Path, lines.Text())
+			} else {
+				file.Comments++
+				s.debug("CELL", file.Path, lines.Text())
+			}
+			if s.keep {
+				file.classified = append(file.classified, classifiedLine{text, class})
+			}
+		}
+	}
+	return nil
+}
+
+// The text of a cell, saved either as a list of lines or as one string
+func cellSource(raw json.RawMessage) string {
+	lines := []string{}
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+	text := ""
+	json.Unmarshal(raw, &text)
+	return text
+}
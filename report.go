@@ -0,0 +1,226 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the report layout, raised when fields change meaning
+const ReportSchema = 1
+
+// Report is the complete outcome of a scan for encoding
+type Report struct {
+	XMLName    xml.Name    `json:"-" xml:"codecount"`
+	Schema     int         `json:"schema" xml:"schema,attr"`
+	Files      Files       `json:"files" xml:"files>file"`
+	Totals     Count       `json:"totals" xml:"totals"`
+	ByLanguage []Group     `json:"byLanguage" xml:"byLanguage>language"`
+	Generated  *Count      `json:"generated,omitempty" xml:"generated,omitempty"`  // Kept out of the totals
+	Vendored   *Count      `json:"vendored,omitempty" xml:"vendored,omitempty"`    // Kept out of the totals
+	Empty      int         `json:"empty,omitempty" xml:"empty,omitempty"`          // Files counted holding only whitespace
+	Binary     []string    `json:"binary,omitempty" xml:"binary>path,omitempty"`   // Paths skipped
+	Invalid    []string    `json:"invalid,omitempty" xml:"invalid>path,omitempty"` // Paths skipped as not valid text
+	Large      []string    `json:"large,omitempty" xml:"large>path,omitempty"`     // Paths skipped as too large
+	Diff       *DiffResult `json:"diff,omitempty" xml:"-"`                         // Lines changed over a git range
+	Links      int         `json:"links,omitempty" xml:"links,omitempty"`          // Links to directories skipped
+	Partial    bool        `json:"partial,omitempty" xml:"partial,attr,omitempty"` // Scanning was stopped early
+	Runtime    float64     `json:"runtime" xml:"runtime"`                          // Seconds
+}
+
+// Build the report of the result for a scan that took runtime
+func (r *Result) Report(runtime time.Duration) Report {
+	report := Report{
+		Schema:     ReportSchema,
+		Files:      r.Files,
+		Totals:     r.Totals(),
+		ByLanguage: r.ByLanguage(),
+		Empty:      r.EmptyTotals().Files,
+		Binary:     r.Binaries().Paths(),
+		Invalid:    r.Invalid().Paths(),
+		Large:      r.Large().Paths(),
+		Links:      r.Links,
+		Partial:    r.Partial,
+		Runtime:    runtime.Seconds(),
+	}
+	if generated := r.GeneratedTotals(); generated.Files > 0 {
+		report.Generated = &generated
+	}
+	if vendored := r.VendoredTotals(); vendored.Files > 0 {
+		report.Vendored = &vendored
+	}
+	return report
+}
+
+// Write the report as YAML with the same fields as the JSON encoding
+func (r Report) WriteYAML(w io.Writer) error {
+	var err error
+	printf := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+	counts := func(indent string, c Count) {
+		printf("%sfiles: %d\n%sblanks: %d\n%scomments: %d\n%scode: %d\n%slines: %d\n",
+			indent, c.Files, indent, c.Blanks, indent, c.Comments, indent, c.Code, indent, c.Lines)
+		if c.Mixed != 0 {
+			printf("%smixed: %d\n", indent, c.Mixed)
+		}
+		if c.Docs != 0 {
+			printf("%sdocs: %d\n", indent, c.Docs)
+		}
+		if c.Complexity != 0 {
+			printf("%scomplexity: %d\n", indent, c.Complexity)
+		}
+		if c.Functions != 0 {
+			printf("%sfunctions: %d\n", indent, c.Functions)
+		}
+		if c.Longest != 0 {
+			printf("%slongest: %d\n%schars: %d\n", indent, c.Longest, indent, c.Chars)
+		}
+		printf("%sbytes: %d\n", indent, c.Bytes)
+	}
+
+	printf("schema: %d\n", r.Schema)
+	printf("files:%s\n", emptyList(len(r.Files)))
+	for _, file := range r.Files {
+		f := file.record()
+		printf("  - name: %s\n    path: %s\n", strconv.Quote(f.Name), strconv.Quote(f.Path))
+		printf("    code: %d\n    blanks: %d\n    comments: %d\n    lines: %d\n",
+			f.Code, f.Blanks, f.Comments, f.Lines)
+		if f.Mixed != 0 {
+			printf("    mixed: %d\n", f.Mixed)
+		}
+		if f.Docs != 0 {
+			printf("    docs: %d\n", f.Docs)
+		}
+		if f.Complexity != 0 {
+			printf("    complexity: %d\n", f.Complexity)
+		}
+		if f.Functions != 0 {
+			printf("    functions: %d\n", f.Functions)
+		}
+		if f.Longest != 0 {
+			printf("    longest: %d\n    chars: %d\n", f.Longest, f.Chars)
+		}
+		printf("    bytes: %d\n", f.Bytes)
+		printf("    language: %s\n", strconv.Quote(f.Language))
+		if f.Encoding != "" {
+			printf("    encoding: %s\n", f.Encoding)
+		}
+		if f.LineEnding != "" {
+			printf("    lineEnding: %s\n", f.LineEnding)
+		}
+		if f.Hash != "" {
+			printf("    hash: %s\n", f.Hash)
+		}
+		if f.Duplicate != "" {
+			printf("    duplicate: %s\n", strconv.Quote(f.Duplicate))
+		}
+	}
+	printf("totals:\n")
+	counts("  ", r.Totals)
+	printf("byLanguage:%s\n", emptyList(len(r.ByLanguage)))
+	for _, group := range r.ByLanguage {
+		printf("  - name: %s\n", strconv.Quote(group.Name))
+		counts("    ", group.Count)
+	}
+	if r.Generated != nil {
+		printf("generated:\n")
+		counts("  ", *r.Generated)
+	}
+	if r.Vendored != nil {
+		printf("vendored:\n")
+		counts("  ", *r.Vendored)
+	}
+	if r.Empty > 0 {
+		printf("empty: %d\n", r.Empty)
+	}
+	if len(r.Binary) > 0 {
+		printf("binary:\n")
+		for _, path := range r.Binary {
+			printf("  - %s\n", strconv.Quote(path))
+		}
+	}
+	if len(r.Invalid) > 0 {
+		printf("invalid:\n")
+		for _, path := range r.Invalid {
+			printf("  - %s\n", strconv.Quote(path))
+		}
+	}
+	if len(r.Large) > 0 {
+		printf("large:\n")
+		for _, path := range r.Large {
+			printf("  - %s\n", strconv.Quote(path))
+		}
+	}
+	if r.Links > 0 {
+		printf("links: %d\n", r.Links)
+	}
+	if r.Partial {
+		printf("partial: true\n")
+	}
+	printf("runtime: %g\n", r.Runtime)
+	return err
+}
+
+// An empty list is written inline as YAML has no other way to show it
+func emptyList(n int) string {
+	if n == 0 {
+		return " []"
+	}
+	return ""
+}
+
+// Write the totals, unlabelled, and the counts by language as Prometheus
+// gauges in the text format
+func (r Report) WritePrometheus(w io.Writer) error {
+	metrics := []struct {
+		name, help string
+		value      func(Count) int
+	}{
+		{"codecount_files", "Number of files counted.", func(c Count) int { return c.Files }},
+		{"codecount_blank_lines", "Number of blank lines.", func(c Count) int { return c.Blanks }},
+		{"codecount_comment_lines", "Number of comment lines.", func(c Count) int { return c.Comments }},
+		{"codecount_code_lines", "Number of code lines.", func(c Count) int { return c.Code }},
+		{"codecount_lines", "Number of lines.", func(c Count) int { return c.Lines }},
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, metric := range metrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n",
+			metric.name, metric.help, metric.name, metric.name, metric.value(r.Totals))
+		if err != nil {
+			return err
+		}
+		for _, group := range r.ByLanguage {
+			_, err := fmt.Fprintf(w, "%s{language=\"%s\"} %d\n",
+				metric.name, escape.Replace(group.Name), metric.value(group.Count))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "# HELP codecount_scan_seconds Time taken by the scan.\n"+
+		"# TYPE codecount_scan_seconds gauge\ncodecount_scan_seconds %g\n", r.Runtime)
+	return err
+}
@@ -0,0 +1,284 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reporter writes a result in a format of its own: it is started, given
+// each file of the result in the order scanned, then finished with the
+// totals. Reporters are registered by name so that programs embedding the
+// package can add formats of their own.
+type Reporter interface {
+	Start() error
+	File(file File) error
+	Finish(totals Totals) error
+}
+
+// Totals is what a reporter is given once it has been given every file
+type Totals struct {
+	Count                    // The files counted
+	ByLanguage []Group       // The files counted by language
+	Empty      Count         // Counted files holding only whitespace
+	Generated  Count         // Kept out of the totals
+	Vendored   Count         // Kept out of the totals
+	Duplicates Count         // Kept out of the totals
+	Binary     int           // Files skipped as their content is not text
+	Invalid    int           // Files skipped as not valid in their encoding
+	Large      int           // Files skipped as larger than the maximum size
+	Links      int           // Links to directories skipped
+	Partial    bool          // Scanning was stopped before every file was counted
+	Runtime    time.Duration // How long the scan took
+	Summary    *Summary      // The files folded in when streaming, nil otherwise
+}
+
+func init() {
+	RegisterReporter("text", func(w io.Writer) Reporter { return &textReporter{w: w} })
+	RegisterReporter("json", func(w io.Writer) Reporter { return &jsonReporter{w: w} })
+	RegisterReporter("csv", func(w io.Writer) Reporter { return &csvReporter{w: csv.NewWriter(w)} })
+}
+
+// The totals of the result for a scan that took runtime
+func (r *Result) Summarize(runtime time.Duration) Totals {
+	totals := Totals{
+		Count:      r.Totals(),
+		ByLanguage: r.ByLanguage(),
+		Empty:      r.EmptyTotals(),
+		Generated:  r.GeneratedTotals(),
+		Vendored:   r.VendoredTotals(),
+		Duplicates: r.Duplicates().Totals(),
+		Binary:     len(r.Binaries()),
+		Invalid:    len(r.Invalid()),
+		Large:      len(r.Large()),
+		Links:      r.Links,
+		Partial:    r.Partial,
+		Runtime:    runtime,
+		Summary:    r.Summary,
+	}
+	if r.Summary != nil {
+		totals.Duplicates.Add(r.Summary.Duplicates)
+	}
+	return totals
+}
+
+// Give the result of a scan that took runtime to the reporter, each file
+// and then the totals
+func (r *Result) Write(reporter Reporter, runtime time.Duration) error {
+	if err := reporter.Start(); err != nil {
+		return err
+	}
+	for _, file := range r.Files {
+		if err := reporter.File(file); err != nil {
+			return err
+		}
+	}
+	return reporter.Finish(r.Summarize(runtime))
+}
+
+var (
+	reportersMu sync.Mutex
+	reporters   = map[string]func(w io.Writer) Reporter{}
+)
+
+// Register a format by name, the function making a reporter writing a
+// result to w; a name registered again is replaced
+func RegisterReporter(name string, reporter func(w io.Writer) Reporter) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporters[strings.ToLower(name)] = reporter
+}
+
+// A reporter of the format registered by the name, writing to w
+func NewReporter(name string, w io.Writer) (Reporter, error) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporter, found := reporters[strings.ToLower(name)]
+	if !found {
+		return nil, fmt.Errorf("no reporter %q, want one of %s", name,
+			strings.Join(reporterNames(), ", "))
+	}
+	return reporter(w), nil
+}
+
+// The names of the formats registered, from A to Z
+func ReporterNames() []string {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	return reporterNames()
+}
+
+func reporterNames() []string {
+	names := []string{}
+	for name := range reporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Writes the totals by language as a table, then the files kept out of
+// the totals
+type textReporter struct {
+	w io.Writer
+}
+
+func (r *textReporter) Start() error         { return nil }
+func (r *textReporter) File(file File) error { return nil }
+
+func (r *textReporter) Finish(totals Totals) error {
+	var err error
+	printf := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(r.w, format, a...)
+		}
+	}
+	rule := strings.Repeat("-", 79) + "\n"
+	line := func(name string, c Count) {
+		printf("%-29s%10d%10d%10d%10d%10d\n", name, c.Files, c.Blanks, c.Comments, c.Code, c.Lines)
+	}
+
+	printf(rule)
+	printf("%-29s%10s%10s%10s%10s%10s\n", "Grouping", "Files", "Blank", "Comment", "Code", "Lines")
+	printf(rule)
+	for _, group := range totals.ByLanguage {
+		line(group.Name, group.Count)
+	}
+	printf(rule)
+	line("Totals", totals.Count)
+	printf(rule)
+	for _, excluded := range []struct {
+		name  string
+		count Count
+	}{
+		{"Empty (included)", totals.Empty},
+		{"Generated (excluded)", totals.Generated},
+		{"Vendored (excluded)", totals.Vendored},
+		{"Duplicates (excluded)", totals.Duplicates},
+		{"Skipped binary", Count{Files: totals.Binary}},
+		{"Skipped invalid", Count{Files: totals.Invalid}},
+		{"Skipped large", Count{Files: totals.Large}},
+		{"Skipped links", Count{Files: totals.Links}},
+	} {
+		if excluded.count.Files > 0 {
+			line(excluded.name, excluded.count)
+			printf(rule)
+		}
+	}
+	if totals.Partial {
+		printf("Partial, scanning was stopped\n")
+	}
+	printf("Runtime:  %s\n", totals.Runtime)
+	return err
+}
+
+// Writes the JSON report, each file as it is given
+type jsonReporter struct {
+	w                      io.Writer
+	files                  int
+	binary, invalid, large []string
+}
+
+func (r *jsonReporter) Start() error {
+	_, err := fmt.Fprintf(r.w, `{"schema":%d,"files":[`, ReportSchema)
+	return err
+}
+
+func (r *jsonReporter) File(file File) error {
+	switch {
+	case file.Binary:
+		r.binary = append(r.binary, file.Path)
+	case file.Invalid:
+		r.invalid = append(r.invalid, file.Path)
+	case file.Large:
+		r.large = append(r.large, file.Path)
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if r.files > 0 {
+		data = append([]byte{','}, data...)
+	}
+	r.files++
+	_, err = r.w.Write(data)
+	return err
+}
+
+// The fields after the files are those of the report encoded without files
+func (r *jsonReporter) Finish(totals Totals) error {
+	report := Report{
+		Files:      Files{},
+		Totals:     totals.Count,
+		ByLanguage: totals.ByLanguage,
+		Empty:      totals.Empty.Files,
+		Binary:     r.binary,
+		Invalid:    r.invalid,
+		Large:      r.large,
+		Links:      totals.Links,
+		Partial:    totals.Partial,
+		Runtime:    totals.Runtime.Seconds(),
+	}
+	if totals.Generated.Files > 0 {
+		report.Generated = &totals.Generated
+	}
+	if totals.Vendored.Files > 0 {
+		report.Vendored = &totals.Vendored
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	empty := []byte(`"files":[`)
+	data = data[bytes.Index(data, empty)+len(empty):]
+	if _, err := r.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(r.w, "\n")
+	return err
+}
+
+// Writes the totals by language as CSV with a header row
+type csvReporter struct {
+	w *csv.Writer
+}
+
+func (r *csvReporter) Start() error {
+	return r.w.Write([]string{"Grouping", "Files", "Blank", "Comment", "Code", "Lines"})
+}
+
+func (r *csvReporter) File(file File) error { return nil }
+
+func (r *csvReporter) Finish(totals Totals) error {
+	rows := append([]Group{}, totals.ByLanguage...)
+	for _, group := range append(rows, Group{"Totals", totals.Count}) {
+		r.w.Write([]string{group.Name, strconv.Itoa(group.Files), strconv.Itoa(group.Blanks),
+			strconv.Itoa(group.Comments), strconv.Itoa(group.Code), strconv.Itoa(group.Lines)})
+	}
+	r.w.Flush()
+	return r.w.Error()
+}
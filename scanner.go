@@ -0,0 +1,1170 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Scanner walks a tree counting the lines of each file with a known
+// language. The zero value is ready to use.
+type Scanner struct {
+	Omit             *regexp.Regexp  // Skip paths matching this expression
+	Only             map[string]bool // When set, only count these cleaned paths
+	NoComments       map[string]bool // Lower case language names counted as code only
+	OnlyComments     map[string]bool // When set, classify comments only for these
+	EmbeddedSQL      bool            // Count SQL in multi-line strings separately
+	CountPartial     bool            // Count code lines with a comment as mixed
+	DocStringsAsCode bool            // Count docstrings as code rather than comments
+	SplitEmbedded    bool            // Count script and style sections on their own
+	IncludeGenerated bool            // Count files written by tools in the totals
+	IncludeVendored  bool            // Count files in vendored directories in the totals
+	FollowSymlinks   bool            // Walk the directories linked to, once each
+	MaxFileSize      int64           // Skip files larger than this many bytes
+	Complexity       bool            // Count the branches in the code
+	Functions        bool            // Count the functions and methods defined
+	VendorDirs       []string        // Names of vendored directories, DefaultVendorDirs when nil
+	Inventory        bool            // Find files without reading them
+	NoGitignore      bool            // Count files ignored by .gitignore
+	Exclude          []string        // Skip paths relative to the root matching these globs
+	Include          []string        // When set, only count files matching these globs
+	Debug            io.Writer       // Receives the classification of each line
+	Cache            *Cache          // When set, reuse the counts of unchanged files
+	Progress         func(File)      // When set, called with each file as it is counted
+	Context          context.Context // When set, scanning stops once it is done
+	Stream           bool            // Fold each file counted into a summary rather than keep it
+
+	keep     bool            // Keep the classified lines of each file for diffing
+	previous map[string]File // Files counted before, reused when unchanged
+	folding  *Summary        // Shared by the roots of a streaming scan
+}
+
+// States for scanning
+const (
+	NORMAL = iota
+	BLOCK
+	END
+	FRONT
+)
+
+// Scan the files or single file at the root
+func (s *Scanner) Scan(root string) (*Result, error) {
+	if IsArchive(root) {
+		if info, err := os.Stat(root); err == nil && info.Mode().IsRegular() {
+			return s.scanArchive(root)
+		}
+	}
+	result := s.newResult()
+	ignores := ignoreStack{}
+	excludes := compileGlobs(s.Exclude)
+	includes := compileGlobs(s.Include)
+	// Real paths of the root and the directories linked to, walked once
+	visited := map[string]bool{}
+	rootReal := realPath(root)
+
+	walker := newWalker()
+	defer walker.Stop()
+	var walk filepath.WalkFunc
+	walk = func(path string, info os.FileInfo, err error) error {
+		// A path that cannot be read below the root is skipped, as one
+		// unreadable directory or a file removed while walking
+		if err != nil {
+			if path == root {
+				return err
+			}
+			s.debug("UNREADABLE", path, "")
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := s.canceled(); err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil {
+				s.debug("BROKEN", path, "")
+				return nil
+			}
+			if !target.IsDir() {
+				info = target
+			} else if !s.FollowSymlinks {
+				s.debug("LINK", path, "")
+				result.Links++
+				return nil
+			} else {
+				real := realPath(path)
+				if visited[real] || real == rootReal ||
+					strings.HasPrefix(real, rootReal+string(filepath.Separator)) {
+
+					s.debug("LOOP", path, "")
+					result.Links++
+					return nil
+				}
+				visited[real] = true
+				// A trailing separator has the directory linked to walked
+				return walker.Walk(path+string(filepath.Separator), walk)
+			}
+		}
+		if s.Omit != nil {
+			if s.Omit.MatchString(path) {
+				return nil
+			}
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+			rel = filepath.ToSlash(rel)
+			if matchGlobs(excludes, rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() && len(includes) > 0 &&
+				!matchGlobs(includes, rel, false) {
+
+				return nil
+			}
+		}
+		if !s.NoGitignore {
+			ignores = ignores.enter(path)
+			if ignores.ignored(path, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info.IsDir() {
+			if path != "." {
+				name := info.Name()
+				if strings.HasPrefix(name, ".") {
+					return filepath.SkipDir
+				} else if name == "__pycache__" {
+					return filepath.SkipDir
+				}
+			}
+			if !s.NoGitignore {
+				// An ignore file that cannot be read has no rules, as
+				// for the directory the walk tells of the error next
+				ignore, err := readIgnore(path)
+				if err != nil {
+					s.debug("UNREADABLE", filepath.Join(path, ".gitignore"), "")
+				}
+				if ignore != nil {
+					ignores = append(ignores, ignore)
+				}
+			}
+		} else {
+			if s.Only != nil && !s.Only[filepath.Clean(path)] {
+				return nil
+			}
+			if file, found := s.unchanged(path, info); found {
+				s.keepFile(result, file)
+				return nil
+			}
+			return s.add(result, File{Path: path, Info: info, Root: root})
+		}
+		return nil
+	}
+	err := walker.Walk(root, walk)
+	result.Partial = err != nil && err == s.canceled()
+	result.markDuplicates()
+	return result, err
+}
+
+// The error of the context when scanning was stopped, nil otherwise
+func (s *Scanner) canceled() error {
+	if s.Context == nil {
+		return nil
+	}
+	return s.Context.Err()
+}
+
+// The absolute path with symbolic links resolved, as well as it can be
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// The start of the content of the file
+func readHead(file *File) ([]byte, error) {
+	f, err := file.reader()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, 8000)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// Is the file over the maximum size to count
+func (s *Scanner) tooLarge(info os.FileInfo) bool {
+	return s.MaxFileSize > 0 && info.Size() > s.MaxFileSize
+}
+
+// The longest line read before giving up on a file
+const maxLineLength = 256 << 20
+
+// Reads lines of any length up to the maximum, where a bufio.Scanner
+// alone stops at 64KB, ended by any of LF, CRLF or CR
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), maxLineLength)
+	scanner.Split((&lineEndings{}).split)
+	return scanner
+}
+
+// Counts of the line endings of the lines split
+type lineEndings struct {
+	lf, crlf, cr int
+}
+
+// Split a line ended by LF, CRLF or CR, the last line may have no ending
+func (e *lineEndings) split(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexAny(data, "\r\n")
+	switch {
+	case i < 0:
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	case data[i] == '\n':
+		e.lf++
+		return i + 1, data[:i], nil
+	case i+1 < len(data) && data[i+1] == '\n':
+		e.crlf++
+		return i + 2, data[:i], nil
+	case i+1 < len(data) || atEOF:
+		e.cr++
+		return i + 1, data[:i], nil
+	}
+	// A CR at the end of the data may yet be followed by LF
+	return 0, nil, nil
+}
+
+// The most common line ending, empty when no line was ended
+func (e *lineEndings) dominant() string {
+	switch {
+	case e.lf == 0 && e.crlf == 0 && e.cr == 0:
+		return ""
+	case e.lf >= e.crlf && e.lf >= e.cr:
+		return "LF"
+	case e.crlf >= e.cr:
+		return "CRLF"
+	}
+	return "CR"
+}
+
+// Is the content binary, judged from its start holding a NUL or mostly
+// bytes that are not text
+func isBinary(head []byte) bool {
+	n := len(head)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+
+	other := 0
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size == 1 {
+			// A character cut at the end of the sample is still text
+			if !utf8.FullRune(head) {
+				break
+			}
+			other++
+		} else if r < ' ' && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != 0x1b {
+			other++
+		}
+		head = head[size:]
+	}
+	return other*10 > n*3
+}
+
+// Names of files written by tools
+var generatedNames = regexp.MustCompile(`(\.pb\.go|_gen\.go|\.pb\.(cc|h)|_pb2(_grpc)?\.py|\.g\.dart|` +
+	`\.generated\.\w+|\.designer\.cs|\.min\.(js|css)|^package-lock\.json|^yarn\.lock|^pnpm-lock\.yaml)$`)
+
+// Markers tools leave at the start of the files they write
+var generatedMarks = regexp.MustCompile(
+	`(?i)(code generated\b.*\bdo not edit|@generated\b|<auto-generated|autogenerated by|` +
+		`generated by\b.*\bdo not (edit|modify)|do not (edit|modify)\b.*\bgenerated)`)
+
+// Is the file written by a tool, from its name or a marker near its start
+func isGenerated(path string, head []byte) bool {
+	if generatedNames.MatchString(filepath.Base(path)) {
+		return true
+	}
+	if len(head) > 2048 {
+		head = head[:2048]
+	}
+	return generatedMarks.Match(head)
+}
+
+// Directories holding the code of dependencies
+var DefaultVendorDirs = []string{"vendor", "node_modules", "third_party", "bower_components"}
+
+// Is the file within a vendored directory below its root
+func (s *Scanner) vendored(file *File) bool {
+	dirs := s.VendorDirs
+	if dirs == nil {
+		dirs = DefaultVendorDirs
+	}
+	path := file.Path
+	if rel, err := filepath.Rel(file.Root, file.Path); err == nil && file.Root != "" {
+		path = rel
+	}
+	parts := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	for _, part := range parts {
+		for _, dir := range dirs {
+			if part == dir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Count the file when its language is known and add it to the result
+func (s *Scanner) add(result *Result, file File) error {
+	if err := s.canceled(); err != nil {
+		return err
+	}
+	file.Vendored = !s.IncludeVendored && s.vendored(&file)
+	key := ""
+	if s.Cache != nil && !s.Inventory && !s.keep && !s.tooLarge(file.Info) {
+		key = s.cacheKey(file.Path)
+		if cached, found := s.Cache.lookup(key, file.Info); found {
+			cached.Path = file.Path
+			cached.Root = file.Root
+			cached.Vendored = file.Vendored
+			s.keepFile(result, cached)
+			return nil
+		}
+	}
+	lang, found := Detect(file.Path)
+	if !found && !s.Inventory {
+		lang, found = detectFile(&file)
+	}
+	if !found {
+		return nil
+	}
+	file.Lang = lang
+	if s.Inventory {
+		file.Listed = true
+	} else if err := s.scan(&file); err != nil {
+		return err
+	}
+	if key != "" && file.Scanned {
+		s.Cache.store(key, file)
+	}
+	s.keepFile(result, file)
+	return nil
+}
+
+// Keep the file in the result, telling of the progress when asked
+func (s *Scanner) keepFile(result *Result, file File) {
+	if result.Summary != nil && (file.Scanned || file.Listed) {
+		result.Summary.fold(&file)
+	} else {
+		result.Files = append(result.Files, file)
+	}
+	if s.Progress != nil {
+		s.Progress(file)
+	}
+}
+
+// Scan each of the roots into one result, when stopped the result holds
+// the files counted before along with the error
+func (s *Scanner) ScanAll(roots []string) (*Result, error) {
+	result := s.newResult()
+	if s.Stream {
+		s.folding = result.Summary
+		defer func() { s.folding = nil }()
+	}
+	for _, root := range roots {
+		scanned, err := s.Scan(root)
+		if scanned != nil && scanned.Partial {
+			result.Files = append(result.Files, scanned.Files...)
+			result.Partial = true
+			result.markDuplicates()
+			return result, err
+		} else if err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, scanned.Files...)
+		result.Links += scanned.Links
+	}
+	result.markDuplicates()
+	return result, nil
+}
+
+// Scan the roots again, reusing the counts of the files of the previous
+// result whose size and modification time have not changed
+func (s *Scanner) Rescan(roots []string, previous *Result) (*Result, error) {
+	s.previous = map[string]File{}
+	for _, file := range previous.Files {
+		if file.Info != nil {
+			s.previous[file.Path] = file
+		}
+	}
+	defer func() { s.previous = nil }()
+	return s.ScanAll(roots)
+}
+
+// The file as counted before when it is unchanged since
+func (s *Scanner) unchanged(path string, info os.FileInfo) (File, bool) {
+	file, found := s.previous[path]
+	if !found || file.Info.Size() != info.Size() || !file.Info.ModTime().Equal(info.ModTime()) {
+		return File{}, false
+	}
+	file.Info = info
+	file.Duplicate = ""
+	return file, true
+}
+
+// Scan exactly the files listed, skipping those that no longer exist as
+// the names of deleted files are often listed along with the rest; when
+// stopped the result holds the files counted before along with the error
+func (s *Scanner) ScanFiles(paths []string) (*Result, error) {
+	result := s.newResult()
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			s.debug("MISSING", path, "")
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if err := s.add(result, File{Path: path, Info: info}); err == s.canceled() && err != nil {
+			result.Partial = true
+			result.markDuplicates()
+			return result, err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	result.markDuplicates()
+	return result, nil
+}
+
+// Scan the content read as the language, detected from the #! line when
+// not given, naming the file of the result as name; the result is empty
+// when the language is unknown
+func (s *Scanner) ScanReader(r io.Reader, name string, lang Language) (*Result, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	file := File{
+		Path: name,
+		Info: contentInfo{name, int64(len(data))},
+		Lang: lang,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+	if err := s.scan(&file); err != nil {
+		return nil, err
+	}
+	if file.Lang.Name == "" {
+		return &Result{}, nil
+	}
+	return &Result{Files: Files{file}}, nil
+}
+
+// File info for content that is not in a file
+type contentInfo struct {
+	name string
+	size int64
+}
+
+func (c contentInfo) Name() string       { return c.name }
+func (c contentInfo) Size() int64        { return c.size }
+func (c contentInfo) Mode() os.FileMode  { return 0444 }
+func (c contentInfo) ModTime() time.Time { return time.Time{} }
+func (c contentInfo) IsDir() bool        { return false }
+func (c contentInfo) Sys() interface{}   { return nil }
+
+// Scan a single file, it is not scanned when the language is unknown
+func (s *Scanner) ScanFile(path string) (File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+	file := File{Path: path, Info: info}
+	err = s.scan(&file)
+	return file, err
+}
+
+// Should comments be classified for the language
+func (s *Scanner) classifyComments(lang Language) bool {
+	name := strings.ToLower(lang.Name)
+	if s.OnlyComments != nil && !s.OnlyComments[name] {
+		return false
+	}
+	return !s.NoComments[name]
+}
+
+// Write the classification of a path, or of a line in it, when debugging
+func (s *Scanner) debug(class string, path string, line string) {
+	if s.Debug != nil {
+		fmt.Fprintf(s.Debug, "%s\t%s\t%s\n", class, path, line)
+	}
+}
+
+// Scans a single file, recording the stats
+func (s *Scanner) scan(file *File) error {
+	if file.Lang.Name == "" {
+		file.Lang, _ = detectFile(file)
+	}
+	if file.Lang.Name == "YAML" &&
+		ansiblePath.MatchString(filepath.ToSlash(file.Path)) {
+
+		file.Lang = languageNamed("Ansible")
+	}
+	file.Lang = sniffLanguage(file, file.Lang)
+
+	// Skip unknown files
+	if file.Lang.Name == "" {
+		file.Scanned = false
+		return nil
+	}
+
+	// An empty file has nothing to read but is still a file of the repo
+	if file.Info.Size() == 0 {
+		s.debug("EMPTY", file.Path, "")
+		file.Scanned = true
+		return nil
+	}
+
+	// Skip files too large to be source, like database dumps
+	if s.tooLarge(file.Info) {
+		s.debug("LARGE", file.Path, "")
+		file.Large = true
+		file.Scanned = false
+		return nil
+	}
+
+	// Skip content that is not text whatever its name
+	head, err := readHead(file)
+	if err != nil {
+		return err
+	}
+	// UTF-16 has a NUL in every other byte of ASCII, taken as binary
+	// unless it is known by its byte order mark
+	file.Encoding = textEncoding(head, file.Info.Size())
+	if file.Encoding != "" {
+		head = decodeUTF16(head, file.Encoding)
+	}
+	if isBinary(head) {
+		s.debug("BINARY", file.Path, "")
+		file.Binary = true
+		file.Scanned = false
+		return nil
+	}
+	if !s.IncludeGenerated && isGenerated(file.Path, head) {
+		s.debug("GENERATED", file.Path, "")
+		file.Generated = true
+	}
+
+	// Notebooks are JSON holding the cells to count
+	if file.Lang.Name == "Jupyter Notebook" {
+		return s.scanNotebook(file)
+	}
+	if file.Lang.Sections || s.SplitEmbedded && file.Lang.Name == "HTML" {
+		return s.scanSections(file)
+	}
+
+	// Open the file to begin scanning
+	f, err := file.text()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Hash the content as it is read to find duplicates, checking the
+	// encoding as it goes
+	hash := sha1.New()
+	checker := &utf8Checker{}
+	if err := s.scanLines(file, io.TeeReader(f, io.MultiWriter(hash, checker))); err != nil {
+		return err
+	}
+	if !checker.Valid() {
+		s.skipInvalid(file)
+		return nil
+	}
+	file.Hash = hex.EncodeToString(hash.Sum(nil))
+	file.Scanned = true
+	return nil
+}
+
+// Skip a file whose content is not valid in its encoding, the counts
+// made of it are not to be trusted
+func (s *Scanner) skipInvalid(file *File) {
+	s.debug("INVALID", file.Path, "")
+	file.Invalid = true
+	file.Scanned = false
+	file.Lines, file.Blanks, file.Comments, file.Code, file.Mixed, file.Docs = 0, 0, 0, 0, 0, 0
+	file.Complexity, file.Functions, file.Longest, file.Chars = 0, 0, 0, 0
+	file.Embedded, file.classified, file.Hash = nil, nil, ""
+}
+
+// Lines read between checks that the scan was stopped, so that a large
+// file does not hold it up
+const cancelLines = 4096
+
+// Read line by line to classify into the counts of the file
+func (s *Scanner) scanLines(file *File, r io.Reader) error {
+	state := NORMAL
+
+	// YAML is Ansible once a play is seen, and the line is in an entry
+	// of a sequence at the top level, where the keys of a play are
+	ansible, entry := false, false
+	classify := s.classifyComments(file.Lang)
+
+	// Multi-line string literal being checked for SQL
+	quote, rest := "", ""
+	decided, isSQL := false, false
+
+	// Closing line of Markdown front matter, or of a fenced code
+	// block and the language it holds
+	fence, fenced := "", ""
+
+	// Terminator of a here document
+	heredoc := ""
+
+	// Inside a \begin{code} environment of literate source
+	literate := false
+
+	// Block comment pair in use and the depth of nested comments
+	opener, closer, nested := "", "", false
+	depth := 0
+
+	before, last := Count{}, ""
+
+	// The last line that was not blank, when it carries on into the line
+	// a string starting the line is not a docstring
+	prior := ""
+
+	// The block comment open is documentation, and the Go comment lines
+	// waiting on a declaration to be documentation.  The branches and
+	// definitions of the line are counted as well when asked.
+	block, docBlock, pending := false, false, 0
+	finish := func() {
+		if s.Complexity && file.Code > before.Code {
+			file.Complexity += file.Lang.branches(last)
+		}
+		if s.Functions && file.Code > before.Code && file.Lang.defines(last) {
+			file.Functions++
+		}
+		switch {
+		case file.Comments == before.Comments:
+			if pending > 0 && goDeclaration.MatchString(last) {
+				file.Docs += pending
+			}
+			pending = 0
+		case file.Lang.Name == "Go":
+			pending++
+		case block:
+			if docBlock {
+				file.Docs++
+			}
+		case file.Lang.startsDoc(last):
+			file.Docs++
+			docBlock = state == BLOCK
+		default:
+			docBlock = false
+		}
+	}
+
+	// Lines of the front matter, read again as content when no fence
+	// closes it by the end of the file
+	fronted, unclosed, replay := []string{}, false, []string{}
+
+	endings := &lineEndings{}
+	scanner := newLineScanner(r)
+	scanner.Split(endings.split)
+	for {
+		var line_orig string
+		if len(replay) > 0 {
+			line_orig, replay = replay[0], replay[1:]
+		} else if scanner.Scan() {
+			line_orig = scanner.Text()
+		} else if state == FRONT {
+			state, fence, unclosed, replay = NORMAL, "", true, fronted
+			file.Lines, file.Blanks, file.Comments, file.Code, file.Mixed, file.Docs = 0, 0, 0, 0, 0, 0
+			file.Complexity, file.Functions, file.Longest, file.Chars = 0, 0, 0, 0
+			file.Embedded, file.classified = nil, nil
+			before, last, prior, block, docBlock, pending = Count{}, "", "", false, false, 0
+			continue
+		} else {
+			break
+		}
+		if file.Lines%cancelLines == cancelLines-1 {
+			if err := s.canceled(); err != nil {
+				return err
+			}
+		}
+		if s.keep && file.Lines > 0 {
+			file.keep(last, before)
+		}
+		finish()
+		before = file.count()
+		block = state == BLOCK
+		file.Lines++
+		length := utf8.RuneCountInString(line_orig)
+		file.Chars += length
+		if length > file.Longest {
+			file.Longest = length
+		}
+
+		line := file.Lang.unwrap(strings.TrimSpace(line_orig))
+		if last != "" {
+			prior = last
+		}
+		last = line
+
+		// YAML or TOML front matter at the top of a Markdown file
+		// is configuration, counted in its own bucket
+		if file.Lines == 1 && file.Lang.Name == "Markdown" && !unclosed &&
+			(line == "---" || line == "+++") {
+
+			state = FRONT
+			fronted = append(fronted, line_orig)
+			fence = line
+			file.Code++
+			front := file.embed("Front Matter")
+			front.Code++
+			front.Lines++
+			s.debug("FRNT", file.Path, line_orig)
+			continue
+		}
+		if state == FRONT {
+			fronted = append(fronted, line_orig)
+			front := file.embed("Front Matter")
+			front.Lines++
+			if line == "" {
+				file.Blanks++
+				front.Blanks++
+			} else if strings.HasPrefix(line, "#") {
+				file.Comments++
+				front.Comments++
+			} else {
+				file.Code++
+				front.Code++
+				if line == fence {
+					state = NORMAL
+					fence = ""
+				}
+			}
+			s.debug("FRNT", file.Path, line_orig)
+			continue
+		}
+
+		if line == "" {
+			file.Blanks++
+			if fence != "" && fenced != "" {
+				code := file.embed(fenced)
+				code.Blanks++
+				code.Lines++
+			}
+			s.debug("BLNK", file.Path, line_orig)
+			continue
+		}
+
+		// Markdown prose is commentary around the fenced code blocks,
+		// which are code in the language given after the fence
+		if file.Lang.Name == "Markdown" {
+			if fence != "" && strings.HasPrefix(line, fence) {
+				fence = ""
+				file.Code++
+				s.debug("FENC", file.Path, line_orig)
+			} else if fence != "" {
+				file.Code++
+				if fenced != "" {
+					code := file.embed(fenced)
+					code.Code++
+					code.Lines++
+				}
+				s.debug("CODE", file.Path, line_orig)
+			} else if m := markdownFence.FindStringSubmatch(line); m != nil {
+				fence, fenced = m[1], languageTagged(m[2]).Name
+				file.Code++
+				s.debug("FENC", file.Path, line_orig)
+			} else {
+				file.Comments++
+				s.debug("LCOM", file.Path, line_orig)
+			}
+			continue
+		}
+
+		// Free format RPGLE has no columns, comments are only //
+		if file.Lines == 1 && file.Lang.Name == "RPGLE" &&
+			strings.EqualFold(line, "**FREE") {
+
+			file.Lang.ColComment = ""
+		}
+
+		if file.Lang.Name == "YAML" && !ansible {
+			if strings.HasPrefix(line_orig, "- ") {
+				entry = true
+			} else if !strings.HasPrefix(line_orig, " ") {
+				entry = false
+			}
+			ansible = ansibleKey.MatchString(line_orig) &&
+				(entry || !strings.HasPrefix(line_orig, " "))
+		}
+
+		// The body of a here document is code up to its terminator
+		if heredoc != "" {
+			if line == heredoc {
+				heredoc = ""
+			}
+			file.Code++
+			s.debug("HDOC", file.Path, line_orig)
+			continue
+		}
+
+		// Inside a multi-line string literal, decide from the first
+		// text in the string if it is SQL
+		if quote != "" {
+			if !decided {
+				decided = true
+				isSQL = sqlStart.MatchString(line)
+			}
+			if strings.Count(line, quote)%2 == 1 {
+				quote = ""
+			}
+			file.Code++
+			if isSQL {
+				sql := file.embed("Embedded SQL")
+				sql.Code++
+				sql.Lines++
+			}
+			s.debug("STRG", file.Path, line_orig)
+			continue
+		}
+
+		// Literate source is commentary around the source lines,
+		// which are marked by > or in a code environment
+		if file.Lang.Literate && state == NORMAL {
+			if line == `\begin{code}` || line == `\end{code}` {
+				literate = line == `\begin{code}`
+				file.Comments++
+				s.debug("LITR", file.Path, line_orig)
+				continue
+			} else if strings.HasPrefix(line, ">") && !literate {
+				line = strings.TrimSpace(line[1:])
+				if line == "" {
+					file.Blanks++
+					s.debug("BLNK", file.Path, line_orig)
+					continue
+				}
+			} else if !literate {
+				file.Comments++
+				s.debug("LITR", file.Path, line_orig)
+				continue
+			}
+		}
+
+		// Comments are not classified, everything else is code
+		if !classify {
+			file.Code++
+			s.debug("CODE", file.Path, line_orig)
+			continue
+		}
+
+		/* In each line take the current state and decide
+		if conditions for another state have come up.
+		Start with the NORMAL state, if a block is opened
+		but not closed then the next line will resume in the
+		BLOCK state.  If the end marker has been encountered,
+		all further lines are in the END state.
+		*/
+		switch state {
+		case NORMAL:
+			if file.Lang.isColComment(line_orig) {
+				file.Comments++
+				s.debug("LCOM", file.Path, line_orig)
+				continue
+			}
+
+			// A block opener that starts with a line marker, like
+			// ### in CoffeeScript, is a block rather than a line
+			if file.Lang.isComment(line) && !file.Lang.startsBlock(line) {
+				file.Comments++
+				s.debug("LCOM", file.Path, line_orig)
+				continue
+			}
+
+			if strings.HasPrefix(line, file.Lang.EndMark) &&
+				file.Lang.EndMark != "" {
+
+				state = END
+				file.Comments++
+				s.debug("ECOM", file.Path, line_orig)
+				continue
+			}
+
+			// A string starting the line, like a Python docstring, is
+			// a comment closed by the same delimiter
+			if doc := file.Lang.docStringAt(line); doc != "" && !s.DocStringsAsCode &&
+				!continuesLine(prior) {
+
+				if strings.Count(line, doc)%2 == 1 {
+					opener, closer, nested = doc, doc, false
+					state = BLOCK
+				}
+				file.Comments++
+				s.debug("DCOM", file.Path, line_orig)
+				continue
+			}
+
+			// Comment markers inside string literals are not seen
+			code, raw := file.Lang.stripStrings(line)
+
+			// Use the second block comment pair when the line has it
+			opener, closer = file.Lang.OpenBlock, file.Lang.CloseBlock
+			nested = file.Lang.Nested
+			if file.Lang.NestOpen != "" &&
+				strings.Contains(code, file.Lang.NestOpen) {
+
+				opener, closer = file.Lang.NestOpen, file.Lang.NestClose
+				nested = true
+			}
+			if long, end := file.Lang.longBracket(code); long != "" {
+				opener, closer, nested = long, end, false
+			}
+
+			if opener != "" && opener == closer {
+				// The same delimiter opens and closes the comment,
+				// an odd count leaves it open for the next line
+				open := strings.Count(code, opener)%2 == 1
+				if strings.HasPrefix(code, opener) {
+					if open {
+						state = BLOCK
+					} else if s.CountPartial &&
+						hasCode(code, opener, closer) {
+
+						s.mixed(file, line_orig)
+						continue
+					}
+					file.Comments++
+					s.debug("BCOM", file.Path, line_orig)
+					continue
+				} else if open {
+					state = BLOCK
+					s.partial(file, line_orig)
+					continue
+				}
+			} else if nested &&
+				strings.Contains(code, opener) {
+
+				depth = nestDepth(code, opener, closer, 0)
+				if depth > 0 && !strings.HasPrefix(code, opener) {
+					state = BLOCK
+					s.partial(file, line_orig)
+					continue
+				} else if depth > 0 {
+					state = BLOCK
+					file.Comments++
+					s.debug("OCOM", file.Path, line_orig)
+					continue
+				} else if s.CountPartial && hasCode(code, opener, closer) {
+					s.mixed(file, line_orig)
+					continue
+				}
+				file.Comments++
+				s.debug("BCOM", file.Path, line_orig)
+				continue
+			} else if opener != "" &&
+				closer != "" {
+
+				spos := strings.LastIndex(code, opener)
+				epos := strings.LastIndex(code, closer)
+
+				if spos > epos && spos > 1 {
+					state = BLOCK
+					s.partial(file, line_orig)
+					continue
+				} else if spos > epos {
+					state = BLOCK
+					file.Comments++
+					s.debug("OCOM", file.Path, line_orig)
+					continue
+				} else if spos < epos && spos > -1 &&
+					s.CountPartial && hasCode(code, opener, closer) {
+
+					s.mixed(file, line_orig)
+					continue
+				} else if spos < epos && spos > -1 {
+					state = NORMAL
+					file.Comments++
+					s.debug("BCOM", file.Path, line_orig)
+					continue
+				}
+			}
+
+			if s.CountPartial && (file.Lang.hasComment(code) ||
+				opener != "" && strings.Contains(code, opener)) {
+
+				s.mixed(file, line_orig)
+			} else {
+				file.Code++
+				s.debug("CODE", file.Path, line_orig)
+			}
+
+			if delims, found := sqlDelims[file.Lang.Name]; found && s.EmbeddedSQL {
+				quote, rest = openString(line, delims)
+				rest = strings.TrimSpace(rest)
+				decided = rest != ""
+				isSQL = decided && sqlStart.MatchString(rest)
+			}
+
+			heredoc = file.Lang.hereDoc(line)
+
+			// A raw string left open carries on as code
+			if raw != "" && quote == "" {
+				quote, decided, isSQL = raw, true, false
+			}
+
+		case BLOCK:
+			spos := strings.LastIndex(line, opener)
+			epos := strings.LastIndex(line, closer)
+			closed := spos < epos && epos != -1
+			if opener == closer {
+				closed = strings.Count(line, closer)%2 == 1
+			} else if nested {
+				depth = nestDepth(line, opener, closer, depth)
+				closed = depth == 0
+			}
+
+			if closed && s.CountPartial &&
+				strings.TrimSpace(line[epos+len(closer):]) != "" {
+
+				state = NORMAL
+				s.mixed(file, line_orig)
+				continue
+			}
+
+			if closed {
+				state = NORMAL
+				s.debug("CCOM", file.Path, line_orig)
+			} else {
+				s.debug("BCOM", file.Path, line_orig)
+			}
+			file.Comments++
+
+		case END:
+			file.Comments++
+			s.debug("ECOM", file.Path, line_orig)
+		}
+
+	}
+	if s.keep && file.Lines > 0 {
+		file.keep(last, before)
+	}
+	finish()
+	if ansible {
+		file.Lang = languageNamed("Ansible")
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", file.Path, err)
+	}
+	file.LineEnding = endings.dominant()
+	return nil
+}
+
+// Count a line of code that also holds a comment
+func (s *Scanner) mixed(file *File, line_orig string) {
+	file.Code++
+	file.Mixed++
+	s.debug("MIXD", file.Path, line_orig)
+}
+
+// Count a line of code opening a block comment, as mixed when partial
+// lines are counted
+func (s *Scanner) partial(file *File, line_orig string) {
+	if s.CountPartial {
+		s.mixed(file, line_orig)
+		return
+	}
+	file.Code++
+	s.debug("COCM", file.Path, line_orig)
+}
+
+// Is there code before the block comment opening or after it closes
+func hasCode(line string, opener string, closer string) bool {
+	end := strings.LastIndex(line, closer)
+	return strings.Index(line, opener) > 0 ||
+		end > -1 && strings.TrimSpace(line[end+len(closer):]) != ""
+}
+
+// Follow the block comments opening and closing through the line,
+// returning the nesting depth at the end of it
+func nestDepth(line string, opener string, closer string, depth int) int {
+	for i := 0; i < len(line); {
+		if strings.HasPrefix(line[i:], opener) {
+			depth++
+			i += len(opener)
+		} else if depth > 0 && strings.HasPrefix(line[i:], closer) {
+			depth--
+			i += len(closer)
+		} else {
+			i++
+		}
+	}
+	return depth
+}
+
+// Find a string literal left open at the end of the line, returning
+// the delimiter and the text following it
+func openString(line string, delims []string) (string, string) {
+	for _, delim := range delims {
+		if strings.Count(line, delim)%2 == 1 {
+			return delim, line[strings.LastIndex(line, delim)+len(delim):]
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,136 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The tags opening a section in another language and its language
+var sectionTags = map[string]string{
+	"template": "HTML",
+	"script":   "Javascript",
+	"style":    "CSS",
+}
+
+// A section tag at the start of a line and its lang attribute
+var (
+	sectionTag  = regexp.MustCompile(`^<(template|script|style)\b`)
+	sectionLang = regexp.MustCompile(`\blang=["']?(\w+)`)
+)
+
+// The languages named by the lang attribute of a section
+var sectionLangs = map[string]string{
+	"ts":         "TypeScript",
+	"typescript": "TypeScript",
+	"tsx":        "TSX",
+	"jsx":        "JSX",
+}
+
+// Count a file made of sections in other languages, like the template,
+// script and style of a Vue component or the scripts of an HTML page.
+// The lines outside the sections, including the tags, are classified as
+// HTML and counted to the file.  Split embedded attributes the lines of
+// each section to its language.
+func (s *Scanner) scanSections(file *File) error {
+	data, err := file.read()
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(data) {
+		s.skipInvalid(file)
+		return nil
+	}
+	hash := sha1.Sum(data)
+	file.Hash = hex.EncodeToString(hash[:])
+	file.Scanned = true
+
+	host := languageNamed("HTML")
+	var text strings.Builder
+	flush := func(lang Language, own bool) error {
+		if text.Len() == 0 {
+			return nil
+		}
+		part := File{Path: file.Path, Lang: lang}
+		err := s.scanLines(&part, strings.NewReader(text.String()))
+		text.Reset()
+		if err != nil {
+			return err
+		}
+		file.merge(&part)
+		if own && s.SplitEmbedded && lang.Name != file.Lang.Name {
+			count := part.count()
+			count.Files = 0
+			file.embed(lang.Name).Add(count)
+		}
+		return nil
+	}
+
+	// The open section, its language and the depth of the same tag
+	// nested in it, like a template inside a template
+	tag, lang, depth := "", host, 0
+	lines := newLineScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if tag == "" {
+			m := sectionTag.FindStringSubmatch(line)
+			text.WriteString(lines.Text() + "\n")
+			if m == nil || strings.Contains(line, "</"+m[1]) {
+				continue
+			}
+			if err := flush(host, false); err != nil {
+				return err
+			}
+			tag, lang, depth = m[1], sectionLanguage(m[1], line), 1
+			continue
+		}
+
+		if strings.HasPrefix(line, "<"+tag) {
+			depth++
+		}
+		if strings.Contains(line, "</"+tag) {
+			depth--
+		}
+		if depth > 0 {
+			text.WriteString(lines.Text() + "\n")
+			continue
+		}
+		if err := flush(lang, true); err != nil {
+			return err
+		}
+		text.WriteString(lines.Text() + "\n")
+		tag, lang = "", host
+	}
+	return flush(lang, tag != "")
+}
+
+// The language of a section by its tag and lang attribute, a language
+// not known is counted as the one of the tag
+func sectionLanguage(tag string, line string) Language {
+	if m := sectionLang.FindStringSubmatch(line); m != nil {
+		if lang := languageNamed(sectionLangs[strings.ToLower(m[1])]); lang.Name != "" {
+			return lang
+		}
+	}
+	return languageNamed(sectionTags[tag])
+}
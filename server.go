@@ -0,0 +1,133 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Server answers counts of the directories below Root over HTTP, at
+// /count?path=dir&format=json and /metrics for Prometheus
+type Server struct {
+	Scanner *Scanner      // Scans the directories asked for
+	Root    string        // Directory holding everything served
+	MaxAge  time.Duration // How long a scan is reused, never when 0
+
+	mu    sync.Mutex
+	cache map[string]*cachedReport
+}
+
+// A report kept to answer requests for the same directory, locked while
+// the directory is scanned so that only one scan of it runs at a time
+type cachedReport struct {
+	mu      sync.Mutex
+	report  Report
+	scanned time.Time
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	switch r.URL.Path {
+	case "/count":
+	case "/metrics":
+		format = "prometheus"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	var contentType string
+	var encode func(Report) error
+	switch format {
+	case "", "json":
+		contentType = "application/json"
+		encode = func(report Report) error { return json.NewEncoder(w).Encode(report) }
+	case "xml":
+		contentType = "application/xml"
+		encode = func(report Report) error { return xml.NewEncoder(w).Encode(report) }
+	case "yaml":
+		contentType = "application/yaml"
+		encode = func(report Report) error { return report.WriteYAML(w) }
+	case "prometheus":
+		contentType = "text/plain; version=0.0.4"
+		encode = func(report Report) error { return report.WritePrometheus(w) }
+	default:
+		http.Error(w, "unknown format "+format, http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.report(s.resolve(r.URL.Query().Get("path")))
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := encode(report); err != nil {
+		s.Scanner.debug("HTTP", r.URL.Path, err.Error())
+	}
+}
+
+// The directory for a path relative to the root, cleaned so that it can
+// never climb out of the root
+func (s *Server) resolve(path string) string {
+	return filepath.Join(s.Root, filepath.Clean("/"+filepath.FromSlash(path)))
+}
+
+// Scan the directory unless it was scanned within MaxAge, the server is
+// locked only to find the report of the directory, not while scanning
+func (s *Server) report(path string) (Report, error) {
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = map[string]*cachedReport{}
+	}
+	cached, found := s.cache[path]
+	if !found {
+		cached = &cachedReport{}
+		s.cache[path] = cached
+	}
+	s.mu.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if !cached.scanned.IsZero() && time.Since(cached.scanned) < s.MaxAge {
+		return cached.report, nil
+	}
+
+	start := time.Now()
+	result, err := s.Scanner.Scan(path)
+	if err != nil {
+		// Paths that fail are not kept, there may be any number of them
+		s.mu.Lock()
+		if cached.scanned.IsZero() && s.cache[path] == cached {
+			delete(s.cache, path)
+		}
+		s.mu.Unlock()
+		return Report{}, err
+	}
+	cached.report, cached.scanned = result.Report(time.Since(start)), start
+	return cached.report, nil
+}
@@ -0,0 +1,122 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// Summary holds the counts of the files a streaming scan folded in, in
+// place of the files themselves, so that memory does not grow with each
+// file; files skipped as binary, invalid or large are still kept
+type Summary struct {
+	Totals     Count             // The counted files
+	Languages  map[string]*Count // The counted files by language and embedded bucket
+	Empty      Count             // The counted files holding only whitespace
+	Generated  Count             // Kept out of the totals
+	Vendored   Count             // Kept out of the totals
+	Duplicates Count             // Kept out of the totals
+
+	seen map[[sha1.Size]byte]bool // Hashes of the content folded in
+}
+
+func newSummary() *Summary {
+	return &Summary{Languages: map[string]*Count{}, seen: map[[sha1.Size]byte]bool{}}
+}
+
+// A result for a scan, folding the files in when streaming
+func (s *Scanner) newResult() *Result {
+	if !s.Stream {
+		return &Result{}
+	}
+	if s.folding != nil {
+		return &Result{Summary: s.folding}
+	}
+	return &Result{Summary: newSummary()}
+}
+
+// Fold the counts of a scanned file into the summary, a file with the same
+// content as one folded in before is a duplicate as markDuplicates has it
+func (summary *Summary) fold(file *File) {
+	count := file.count()
+	duplicate := false
+	if !file.empty() {
+		var hash [sha1.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(file.Hash)); err == nil && n == sha1.Size {
+			duplicate = summary.seen[hash]
+			summary.seen[hash] = true
+		}
+	}
+	if duplicate {
+		summary.Duplicates.Add(count)
+	}
+	if file.Vendored {
+		summary.Vendored.Add(count)
+		return
+	} else if file.Generated {
+		summary.Generated.Add(count)
+		return
+	} else if duplicate {
+		return
+	}
+
+	summary.Totals.Add(count)
+	if file.empty() {
+		summary.Empty.Add(count)
+	}
+	language := func(name string) *Count {
+		if _, found := summary.Languages[name]; !found {
+			summary.Languages[name] = &Count{}
+		}
+		return summary.Languages[name]
+	}
+	lang := language(file.Lang.Name)
+	lang.Add(count)
+	for name, embedded := range file.Embedded {
+		lang.Sub(*embedded)
+		language(name).Add(*embedded)
+	}
+}
+
+// The totals of the counted files holding only whitespace
+func (r *Result) EmptyTotals() Count {
+	totals := r.Empty().Totals()
+	if r.Summary != nil {
+		totals.Add(r.Summary.Empty)
+	}
+	return totals
+}
+
+// The totals of the files written by tools, kept out of the totals
+func (r *Result) GeneratedTotals() Count {
+	totals := r.Generated().Totals()
+	if r.Summary != nil {
+		totals.Add(r.Summary.Generated)
+	}
+	return totals
+}
+
+// The totals of the files of dependencies, kept out of the totals
+func (r *Result) VendoredTotals() Count {
+	totals := r.Vendored().Totals()
+	if r.Summary != nil {
+		totals.Add(r.Summary.Vendored)
+	}
+	return totals
+}
@@ -0,0 +1,16 @@
+// Component with comments inside the markup
+import React from "react";
+
+export function Greeting(props: { name: string }) {
+  const url = `http://example.com/${props.name}`;
+  return (
+    <div>
+      {/* A comment in the markup */}
+      {/*
+        Spanning more than one line
+      */}
+      <a href={url}>Hello {props.name}</a>
+    </div>
+  );
+}
+// Blank = 1, Comment = 6, Code = 9, Total = 16
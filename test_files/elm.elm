@@ -0,0 +1,11 @@
+module Main exposing (main)
+
+{- The entry point
+   {- nested, still a comment -}
+   of the program -}
+import Html exposing (text)
+
+-- Show a greeting
+main =
+    text "-- not a comment" -- greeting
+-- Blank = 2, Comment = 5, Code = 4, Total = 11
@@ -0,0 +1,9 @@
+% Family relations
+:- module(family, [parent/2]).
+
+/* Facts */
+parent(tom, bob).
+parent(bob, ann).
+
+grandparent(X, Z) :- parent(X, Y), parent(Y, Z).
+% Blank = 2, Comment = 3, Code = 4, Total = 9
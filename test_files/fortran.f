@@ -0,0 +1,10 @@
+C     Sum the first ten integers
+      PROGRAM SUM
+      INTEGER I, N
+*     running total
+      N = 0
+      DO 10 I = 1, 10
+   10 N = N + I
+      PRINT *, N
+      END
+C     Blank = 0, Comment = 3, Code = 7, Total = 10
@@ -0,0 +1,9 @@
+# Signing in to the site
+Feature: Sign in
+
+  # The happy path
+  Scenario: Valid password
+    Given a user "ann"
+    When she signs in with "#secret"
+    Then she sees the dashboard
+# Blank = 1, Comment = 3, Code = 5, Total = 9
@@ -0,0 +1,9 @@
+{- Module header
+   {- with a nested comment -}
+   still in the header -}
+module Main where
+
+-- Entry point
+main :: IO ()
+main = putStrLn "{- not a comment"
+{- Blank = 1, Comment = 5, Code = 3, Total = 9 -}
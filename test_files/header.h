@@ -0,0 +1,12 @@
+// Shapes for the renderer
+#pragma once
+
+namespace shapes {
+
+class Circle : public Shape {
+public:
+    double area() const; /* pi r squared */
+};
+
+}
+// Blank = 3, Comment = 3, Code = 6, Total = 12
@@ -0,0 +1,14 @@
+# Here documents among the << operators
+class Config
+  class << self
+    # The settings as text
+    def text
+      items = []
+      items << "x"
+      items << <<~TEXT
+        # part of the text
+      TEXT
+    end
+  end
+end
+# Blank = 0, Comment = 3, Code = 11, Total = 14
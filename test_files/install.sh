@@ -0,0 +1,15 @@
+#!/bin/sh
+# Install the configuration
+
+cat > /etc/app.conf <<EOF
+# not a comment, part of the file
+port = 8080
+EOF
+
+cat <<-'DONE'
+	# still part of the document
+	DONE
+grep -c x <<< "# a here string"
+echo "a << b"
+# done
+# Blank = 2, Comment = 4, Code = 9, Total = 15
@@ -0,0 +1,10 @@
+#= Statistics helpers
+   #= nested =#
+=#
+# The mean of the values
+mean(xs) = sum(xs) / length(xs)
+
+doc = """
+# not a comment
+"""
+# Blank = 1, Comment = 5, Code = 4, Total = 10
@@ -0,0 +1,12 @@
+/* Greeter
+   /* nested inside the header */
+   still the header */
+package greet
+
+// The template holds comment markers
+val template = """
+    // not a comment
+"""
+
+fun greet(name: String) = "Hello /* $name */"
+// Blank = 2, Comment = 5, Code = 5, Total = 12
@@ -0,0 +1,16 @@
+--[[
+  Module for greeting
+]]
+local M = {}
+
+-- Say hello
+function M.greet(name)
+  local s = "--[[ not a comment"
+  --[==[ a comment holding ]] and
+  still going ]==]
+  ---[[ a line comment
+  return "Hello " .. name --[[ trailing ]]
+end
+
+return M
+-- Blank = 2, Comment = 9, Code = 5, Total = 16
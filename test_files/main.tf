@@ -0,0 +1,11 @@
+/*
+ * Storage for the build artifacts
+ */
+# The bucket
+resource "aws_s3_bucket" "artifacts" {
+  bucket = "build-artifacts" // shared name
+  policy = <<-EOT
+    # part of the policy document
+  EOT
+}
+// Blank = 0, Comment = 5, Code = 6, Total = 11
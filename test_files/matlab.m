@@ -0,0 +1,8 @@
+%{
+  Plot the signal
+%}
+function plot_signal(x)
+  % draw it
+  plot(x);  % inline
+end
+% Blank = 0, Comment = 5, Code = 3, Total = 8
@@ -0,0 +1,10 @@
+# Accounts schema
+CREATE TABLE `accounts` (
+  id INT NOT NULL AUTO_INCREMENT, -- the key
+  name VARCHAR(64) DEFAULT '#unnamed',
+  PRIMARY KEY (id)
+) ENGINE=InnoDB;
+
+-- seed data
+INSERT INTO accounts (name) VALUES ('root');
+# Blank = 1, Comment = 3, Code = 6, Total = 10
@@ -0,0 +1,9 @@
+#[ Greeting module
+  #[ nested block ]#
+  still a comment ]#
+## Greet someone by name
+proc greet(name: string) =
+  echo "Hello #[ not a comment ", name  # trailing
+
+greet("world")
+# Blank = 1, Comment = 5, Code = 3, Total = 9
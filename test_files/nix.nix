@@ -0,0 +1,10 @@
+# The development shell
+{ pkgs ? import <nixpkgs> {} }:
+
+/* Tools needed
+   to build */
+pkgs.mkShell {
+  name = "dev #1";
+  buildInputs = [ pkgs.go ];
+}
+# Blank = 1, Comment = 4, Code = 5, Total = 10
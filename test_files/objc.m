@@ -0,0 +1,9 @@
+#import <Foundation/Foundation.h>
+
+/* A greeter */
+@implementation Greeter
+- (void)greet {
+    NSLog(@"%@", @"hello"); // log it
+}
+@end
+// Blank = 1, Comment = 2, Code = 6, Total = 9
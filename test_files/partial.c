@@ -0,0 +1,13 @@
+/* Lines holding both code and comments */
+#include <stdio.h>
+
+int main() {
+    int x = 1; // trailing
+    /* leading */ x++;
+    x--; /* trailing block */
+    /* only a comment */
+    int y = 2; /* opens
+    closes */ y++;
+    return x + y;
+}
+// Blank = 1, Comment = 3, Code = 9, Total = 13, Mixed = 5
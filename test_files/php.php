@@ -24,4 +24,4 @@ class Mhistory extends CI_Model {
 
 	}
 }
-# Blank = 3, Comment = 5,  Code = 19, Total = 27
+# Blank = 3, Comment = 6,  Code = 18, Total = 27
@@ -0,0 +1,13 @@
+<#
+.SYNOPSIS
+    Clean the build output
+#>
+param([string]$Path = "./build")
+
+# Remove the directory when present
+if (Test-Path $Path) {
+    Remove-Item -Recurse $Path  <# quietly #>
+    Write-Host "Removed #$Path"
+}
+<# single line block #>
+# Blank = 1, Comment = 8, Code = 4, Total = 13
@@ -0,0 +1,7 @@
+// Add two numbers
+let add = (a, b) => a + b
+
+/* The result
+ */
+let result = add(1, 2)
+// Blank = 1, Comment = 4, Code = 2, Total = 7
@@ -0,0 +1,11 @@
+// Nested block comments
+/* outer
+   /* inner */
+   still outer */
+
+fn main() {
+    let x = 1; /* trailing
+    comment */
+    println!("{}", x);
+}
+// Blank = 1, Comment = 6, Code = 4, Total = 11
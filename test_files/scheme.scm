@@ -0,0 +1,13 @@
+;; Factorial in Scheme
+#| Block comment
+   #| nested |#
+   still comment |#
+(define (fact n)
+  (if (= n 0)
+      1
+      (* n (fact (- n 1)))))
+
+#;(display "disabled")
+(display (fact 5)) #| trailing
+|#
+; Blank = 1, Comment = 7, Code = 5, Total = 13
@@ -0,0 +1,13 @@
+# Install and run the web server
+class web (
+  String $port = '80', # the port listened on
+) {
+  /* the package from the
+     distribution */
+  package { 'nginx':
+    ensure => installed,
+  }
+
+  service { 'nginx': ensure => running }
+}
+# Blank = 1, Comment = 4, Code = 8, Total = 13
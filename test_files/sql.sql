@@ -0,0 +1,10 @@
+-- Orders placed in the last week
+SELECT o.id,
+       o.total -- gross amount
+  FROM orders o
+/* Only shipped
+   orders */
+ WHERE o.status = 'SHIPPED'
+
+   AND o.placed > CURRENT_DATE - 7;
+-- Blank = 1, Comment = 4, Code = 5, Total = 10
@@ -0,0 +1,12 @@
+/* Comment markers inside string literals */
+#include <stdio.h>
+
+int main() {
+    char *url = "http://example.com";
+    printf("/* not a comment */\n");
+    printf("\"/* still not */\"");
+    char quote = '"'; /* a comment */
+    char *open = "/*";
+    return 0;
+}
+// Blank = 1, Comment = 3, Code = 8, Total = 12
@@ -0,0 +1,177 @@
+/*
+###############################################################################
+# Copyright 2014 Cory Lutton                                                  #
+#                                                                             #
+# Licensed under the Apache License, Version 2.0 (the "License");             #
+# you may not use this file except in compliance with the License.            #
+# You may obtain a copy of the License at                                     #
+#                                                                             #
+#    http://www.apache.org/licenses/LICENSE-2.0                               #
+#                                                                             #
+# Unless required by applicable law or agreed to in writing, software         #
+# distributed under the License is distributed on an "AS IS" BASIS,           #
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.    #
+# See the License for the specific language governing permissions and         #
+# limitations under the License.                                              #
+###############################################################################
+*/
+package codecount
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Directories read at once while walking, as reading them is mostly waiting
+// on the disk or the network
+const walkers = 16
+
+// Directories read ahead of the walk and not yet visited, beyond which the
+// rest are read when visited
+const walkAhead = 4096
+
+// Walker walks a tree as filepath.Walk does, calling the WalkFunc for each
+// path one at a time in lexical order, while the directories in each one
+// the WalkFunc accepts are read and their entries stat'd ahead in parallel.
+// The WalkFunc is called for a directory before it is read, and again with
+// the error when reading it fails.
+type walker struct {
+	workers chan struct{}
+	mu      sync.Mutex
+	ahead   map[string]*listing
+	stopped bool
+}
+
+// The entries of a directory, ready once done is closed
+type listing struct {
+	done    chan struct{}
+	infos   []os.FileInfo
+	err     error
+	dropped bool // Skipped by the walk before it was read
+}
+
+func newWalker() *walker {
+	return &walker{workers: make(chan struct{}, walkers), ahead: map[string]*listing{}}
+}
+
+// Walk the tree at root calling walkFn for each file and directory
+func (w *walker) Walk(root string, walkFn filepath.WalkFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = w.walk(root, info, walkFn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// Stop reading ahead once the walk is over, reads waiting to start never do
+func (w *walker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.ahead = map[string]*listing{}
+}
+
+func (w *walker) walk(path string, info os.FileInfo, walkFn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return walkFn(path, info, nil)
+	}
+
+	// A directory skipped is never read by the walk
+	if err := walkFn(path, info, nil); err != nil {
+		return err
+	}
+	infos, err := w.list(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+	// The directories in one walked into are read ahead, each dropped
+	// when skipped, unread when no reader started on it before then
+	for _, entry := range infos {
+		// Hidden directories are never walked
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			w.readAhead(filepath.Join(path, entry.Name()))
+		}
+	}
+	for _, entry := range infos {
+		name := filepath.Join(path, entry.Name())
+		if err := w.walk(name, entry, walkFn); err != nil {
+			if !entry.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+			w.forget(name)
+		}
+	}
+	return nil
+}
+
+// Drop the directory read ahead as it is not walked
+func (w *walker) forget(path string) {
+	w.mu.Lock()
+	if ahead, found := w.ahead[path]; found {
+		ahead.dropped = true
+		delete(w.ahead, path)
+	}
+	w.mu.Unlock()
+}
+
+// The entries of the directory, as read ahead or read now
+func (w *walker) list(path string) ([]os.FileInfo, error) {
+	w.mu.Lock()
+	ahead, found := w.ahead[path]
+	delete(w.ahead, path)
+	w.mu.Unlock()
+	if !found {
+		ahead = &listing{done: make(chan struct{})}
+		w.read(path, ahead)
+	}
+	<-ahead.done
+	return ahead.infos, ahead.err
+}
+
+// Read the directory, its entries ordered by name
+func (w *walker) read(path string, into *listing) {
+	defer close(into.done)
+	f, err := os.Open(path)
+	if err != nil {
+		into.err = err
+		return
+	}
+	into.infos, into.err = f.Readdir(-1)
+	f.Close()
+	sort.Slice(into.infos, func(i, j int) bool {
+		return into.infos[i].Name() < into.infos[j].Name()
+	})
+}
+
+// Start reading the directory when there is room to keep it
+func (w *walker) readAhead(path string) {
+	w.mu.Lock()
+	if w.stopped || len(w.ahead) >= walkAhead {
+		w.mu.Unlock()
+		return
+	}
+	ahead := &listing{done: make(chan struct{})}
+	w.ahead[path] = ahead
+	w.mu.Unlock()
+
+	go func() {
+		w.workers <- struct{}{}
+		defer func() { <-w.workers }()
+		w.mu.Lock()
+		wanted := !w.stopped && !ahead.dropped
+		w.mu.Unlock()
+		if !wanted {
+			close(ahead.done)
+			return
+		}
+		w.read(path, ahead)
+	}()
+}